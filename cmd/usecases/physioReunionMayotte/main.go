@@ -2,17 +2,21 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"os/signal"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	fhir "github.com/LGMorgan/go-fhir"
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+	clients_r4 "github.com/LGMorgan/go-fhir/versions/r4/clients"
 	models_r4 "github.com/LGMorgan/go-fhir/versions/r4/models"
 	"github.com/joho/godotenv"
 	"golang.org/x/text/cases"
@@ -28,119 +32,120 @@ func main() {
 	}
 	apiKey := os.Getenv("ESANTE_API_KEY")
 
-	clientFhir := fhir.New("https://gateway.api.esante.gouv.fr/fhir/v2", "ESANTE-API-KEY", apiKey, fhir.R4)
+	// Stop the crawl cleanly on Ctrl+C
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
 
-	// LIMIT 50
-	clientFhir.SetEntryLimit(10)
-	clientFhir.SetTimeout(30)
+	// One Practitioner lookup is fired per PractitionerRole, pace them to stay under the gateway quota
+	clientFhir := fhir.New("https://gateway.api.esante.gouv.fr/fhir/v2",
+		fhir.WithAuth("ESANTE-API-KEY", apiKey),
+		fhir.WithPageSize(10),
+		fhir.WithTimeout(30*time.Second),
+		fhir.WithRateLimit(5, 5),
+		// A practitioner with several PractitionerRoles is only fetched once
+		fhir.WithCache(clients_r4.NewMemoryCache(0), time.Hour))
 
-	bundleRes := clientFhir.
+	// Step 1: Gather the organizations and the PractitionerRoles working there, across all pages
+	orgMap := make(map[string]*models_r4.Entry)
+	practitionerRoles := []*models_r4.Entry{}
+
+	search := clientFhir.
 		Search(fhirInterface.ORGANIZATION).
 		Where(models_r4.Organization{}.
-			Address.Contains().Value("974")).
+			Address.StartsWith().Value("974")).
 		Or(models_r4.Organization{}.
-			Address.Contains().Value("976")).
+			Address.StartsWith().Value("976")).
 		RevInclude("PractitionerRole:organization").
-		ReturnBundle().Execute()
-
-	res, ok := bundleRes.(*models_r4.BundleResult)
-	if !ok {
-		log.Println("error bundleRes type assertion")
-		return
-	}
-
-	log.Println("✅ Found ", len(res.Entry), " entries in Mayotte or Reunion")
-
-	for {
-		// Step 1: Build organization map from entries
-		orgMap := make(map[string]*models_r4.Entry)
-		practitionerRoles := []models_r4.Entry{}
-
-		for i, e := range res.Entry {
-			if e.GetResourceType() == "Organization" {
-				orgMap[e.GetId()] = &res.Entry[i]
-			} else if e.GetResourceType() == "PractitionerRole" {
-				practitionerRoles = append(practitionerRoles, e)
-			}
+		ReturnBundle()
+	for entry, err := range search.All(ctx) {
+		if err != nil {
+			log.Println("❌ Error searching organizations:", err)
+			return
 		}
+		// The name and address of the organizations are only on the r4 entries
+		e, ok := entry.(*models_r4.Entry)
+		if !ok {
+			log.Printf("⚠️  Unexpected entry %T\n", entry)
+			continue
+		}
+		if e.GetResourceType() == "Organization" {
+			orgMap[e.GetId()] = e
+		} else if e.GetResourceType() == "PractitionerRole" {
+			practitionerRoles = append(practitionerRoles, e)
+		}
+	}
 
-		log.Println("📊 Organizations: ", len(orgMap), " | PractitionerRoles: ", len(practitionerRoles))
+	log.Println("📊 Organizations: ", len(orgMap), " | PractitionerRoles: ", len(practitionerRoles))
 
-		// Step 2: Process PractitionerRole entries
-		for _, prEntry := range practitionerRoles {
-			practitionerId := prEntry.GetPractitionerReference()
-			orgId := prEntry.GetOrganizationReference()
+	// Step 2: Process PractitionerRole entries
+	for _, prEntry := range practitionerRoles {
+		practitionerId := prEntry.GetPractitionerReference()
+		orgId := prEntry.GetOrganizationReference()
 
-			// Get the organization info
-			org := orgMap[orgId]
+		// Get the organization info
+		org := orgMap[orgId]
 
-			if org == nil {
-				log.Printf("⚠️  Organization %s not found for PractitionerRole %s\n", orgId, prEntry.GetId())
-				continue
-			}
-
-			//log.Printf("\n✅ Found: %s works at %s\n", practitionerId, org.Resource.Name)
-			//log.Printf("   Address: %v\n", org.Resource.Address)
+		if org == nil {
+			log.Printf("⚠️  Organization %s not found for PractitionerRole %s\n", orgId, prEntry.GetId())
+			continue
+		}
 
-			// Step 3: Fetch Practitioner ID with qualification-code = 70
-			practitionerRaw := clientFhir.
-				Search(fhirInterface.PRACTITIONER).
-				ById(practitionerId).
-				And(models_r4.Practitioner{}.QualificationCode.Contains().Value("70")).
-				ReturnRaw().
-				Execute()
+		//log.Printf("\n✅ Found: %s works at %s\n", practitionerId, org.Resource.Name)
+		//log.Printf("   Address: %v\n", org.Resource.Address)
 
-			if practitionerRaw == nil {
-				continue
-			}
-
-			var bundle map[string]interface{}
-			err := json.Unmarshal(practitionerRaw.([]byte), &bundle)
-			if err != nil {
-				log.Printf("❌ Error parsing response for %s: %v\n", practitionerId, err)
-				continue
-			}
+		// Step 3: Fetch Practitioner ID with qualification-code = 70
+		practitionerRaw, err := clientFhir.
+			Search(fhirInterface.PRACTITIONER).
+			ById(practitionerId).
+			And(models_r4.Practitioner{}.QualificationCode.Code("70")).
+			ReturnRaw().
+			ExecuteRawContext(ctx)
+		if err != nil {
+			log.Printf("❌ Error fetching practitioner %s: %v\n", practitionerId, err)
+			continue
+		}
 
-			// Accept either a Bundle (with entry) or a single Practitioner resource
-			entries, ok := bundle["entry"].([]interface{})
-			if !ok {
-				if resType, ok := bundle["resourceType"].(string); ok && resType == "Practitioner" {
-					entries = []interface{}{bundle}
-				}
-			}
-			if len(entries) == 0 {
-				continue
-			}
+		var bundle map[string]interface{}
+		err = json.Unmarshal(practitionerRaw, &bundle)
+		if err != nil {
+			log.Printf("❌ Error parsing response for %s: %v\n", practitionerId, err)
+			continue
+		}
 
-			// Extract data from practitioner and organization
-			lastname := ToTile(extractLastnameFromJson(practitionerRaw.([]byte)))
-			firstname := ToTile(extractFirstnameFromJson(practitionerRaw.([]byte)))
-			rpps := extractRppsFromJson(practitionerRaw.([]byte))
-			email := strings.ToLower(extractEmailFromJson(practitionerRaw.([]byte)))
-			phone := strings.ReplaceAll(extractPhoneFromJson(practitionerRaw.([]byte)), " ", "")
-
-			address := extractAddressFromOrganization(org)
-
-			log.Printf("   Name: %s %s\n", firstname, lastname)
-			log.Printf("   Phone: %s\n", phone)
-			log.Printf("   Email: %s\n", email)
-			log.Printf("   RPPS: %s\n", rpps)
-			log.Printf("   Organization: %s (%s)\n", org.Resource.Name, orgId)
-			log.Printf("   Address: %s\n", address.Address)
-			log.Printf("   City: %s\n", address.City)
-			log.Printf("   Zipcode: %d\n", address.Zipcode)
-			log.Printf("   Department: %s\n", address.Department)
-
-			if rpps == "" {
-				continue
+		// Accept either a Bundle (with entry) or a single Practitioner resource
+		entries, ok := bundle["entry"].([]interface{})
+		if !ok {
+			if resType, ok := bundle["resourceType"].(string); ok && resType == "Practitioner" {
+				entries = []interface{}{bundle}
 			}
-
+		}
+		if len(entries) == 0 {
+			continue
 		}
 
-		if res.GetNextLink() == "" {
-			break
+		// Extract data from practitioner and organization
+		lastname := ToTile(extractLastnameFromJson(practitionerRaw))
+		firstname := ToTile(extractFirstnameFromJson(practitionerRaw))
+		rpps := extractRppsFromJson(practitionerRaw)
+		email := strings.ToLower(extractEmailFromJson(practitionerRaw))
+		phone := strings.ReplaceAll(extractPhoneFromJson(practitionerRaw), " ", "")
+
+		address := extractAddressFromOrganization(org)
+
+		log.Printf("   Name: %s %s\n", firstname, lastname)
+		log.Printf("   Phone: %s\n", phone)
+		log.Printf("   Email: %s\n", email)
+		log.Printf("   RPPS: %s\n", rpps)
+		log.Printf("   Organization: %s (%s)\n", org.Resource.Name, orgId)
+		log.Printf("   Address: %s\n", address.Address)
+		log.Printf("   City: %s\n", address.City)
+		log.Printf("   Zipcode: %d\n", address.Zipcode)
+		log.Printf("   Department: %s\n", address.Department)
+
+		if rpps == "" {
+			continue
 		}
-		res = clientFhir.LoadPage().Next(res).Execute().(*models_r4.BundleResult)
+
 	}
 }
 
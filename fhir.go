@@ -3,6 +3,7 @@ package fhir
 import (
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
 	clients_r4 "github.com/LGMorgan/go-fhir/versions/r4/clients"
+	models_r4 "github.com/LGMorgan/go-fhir/versions/r4/models"
 )
 
 type FhirVersion string
@@ -11,10 +12,32 @@ const (
 	R4 FhirVersion = "r4"
 )
 
-func New(baseUrl string, apiKey string, apiValue string, version FhirVersion) fhirInterface.IClient {
-	switch version {
+// HttpError is returned for any non-2xx answer of the server.
+type HttpError = fhirInterface.HttpError
+
+// Authenticator adds credentials to every request, see WithAuthenticator.
+type Authenticator = fhirInterface.IAuthenticator
+
+// OperationOutcomeError carries the issues of an OperationOutcome, use it with errors.As.
+type OperationOutcomeError = models_r4.OperationOutcomeError
+
+// New builds a client for the FHIR server at baseURL, R4 unless WithVersion
+// says otherwise:
+//
+//	clientFhir := fhir.New("https://gateway.api.esante.gouv.fr/fhir/v2",
+//		fhir.WithAuth("ESANTE-API-KEY", apiKey),
+//		fhir.WithTimeout(30*time.Second),
+//		fhir.WithPageSize(50))
+func New(baseURL string, opts ...Option) fhirInterface.IClient {
+	config := fhirInterface.ClientConfig{
+		Version: string(R4),
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	switch FhirVersion(config.Version) {
 	case R4:
-		return clients_r4.NewFhirClient(baseUrl, apiKey, apiValue)
+		return clients_r4.NewFhirClientWithConfig(baseURL, config)
 	default:
 		return nil
 	}
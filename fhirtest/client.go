@@ -0,0 +1,280 @@
+package fhirtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+	models_r4 "github.com/LGMorgan/go-fhir/versions/r4/models"
+)
+
+// FAKE_BASE_URL is the base URL of the fake client, its page links point to it.
+const FAKE_BASE_URL = "http://fhirtest.invalid"
+
+// Client is a fake fhirInterface.IClient for unit-testing code built on the
+// client. It serves canned Bundles registered per resource type and query,
+// records the queries it receives, and never touches the network.
+//
+//	clientFhir := fhirtest.NewClient()
+//	clientFhir.Respond(fhirInterface.ORGANIZATION, models_r4.Organization{}.Name.StartsWith().Value("kine"), page1, page2)
+//	clientFhir.RespondError(fhirInterface.PRACTITIONER, fhirInterface.Param("_id", "404"), &fhirInterface.HttpError{StatusCode: 404})
+type Client struct {
+	Profile     fhirInterface.ServerProfile
+	EntryLimit  int
+	RetryPolicy fhirInterface.RetryPolicy
+	Timeout     int
+	Logger      *slog.Logger
+
+	mu        sync.Mutex
+	responses map[string][]response
+	queries   []Query
+}
+
+// Query is a request received by the fake client.
+type Query struct {
+	ResourceType fhirInterface.ResourceType
+	Uri          string
+	Parameters   fhirInterface.UrlParameters
+	Raw          bool
+}
+
+type response struct {
+	Body []byte
+	Err  error
+}
+
+func NewClient() *Client {
+	return &Client{
+		Profile:    fhirInterface.ESANTE_V2_PROFILE,
+		EntryLimit: DEFAULT_PAGE_SIZE,
+		Logger:     slog.New(slog.NewTextHandler(io.Discard, nil)),
+		responses:  map[string][]response{},
+	}
+}
+
+// Respond registers the pages returned for the search of resourceType with
+// params: the first page answers the search, the next ones LoadPage, their
+// links being set accordingly. Each page is a Bundle in JSON. Calling
+// Respond again for the same query appends pages.
+//
+// A read by id matches the params Param("_id", id), whether the model searches
+// on _id or reads /Type/id.
+func (c *Client) Respond(resourceType fhirInterface.ResourceType, params fhirInterface.UrlParameters, pages ...[]byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := queryKey(resourceType, params.BuildUrlValues())
+	for _, page := range pages {
+		c.responses[key] = append(c.responses[key], response{Body: page})
+	}
+}
+
+// RespondError appends a page failing with err to the search of resourceType
+// with params: registered first it fails the search itself, after Respond it
+// fails the LoadPage of the following page.
+func (c *Client) RespondError(resourceType fhirInterface.ResourceType, params fhirInterface.UrlParameters, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := queryKey(resourceType, params.BuildUrlValues())
+	c.responses[key] = append(c.responses[key], response{Err: err})
+}
+
+// Queries returns the queries received so far, next pages included.
+func (c *Client) Queries() []Query {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Query(nil), c.queries...)
+}
+
+// Reset forgets the registered responses and the received queries.
+func (c *Client) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.responses = map[string][]response{}
+	c.queries = nil
+}
+
+// serve finds the page answering uri and params.
+func (c *Client) serve(ctx context.Context, uri string, params fhirInterface.UrlParameters, raw bool) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if params.Err != nil {
+		return nil, params.Err
+	}
+	path := strings.TrimPrefix(uri, FAKE_BASE_URL)
+	values := params.BuildUrlValues()
+	if u, err := url.Parse(path); err == nil && u.RawQuery != "" {
+		// A next link followed verbatim
+		path = u.Path
+		values = u.Query()
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	resourceType := fhirInterface.ResourceType(segments[0])
+	key, index := "", 0
+	switch {
+	case segments[0] == "_page":
+		// Page links are /_page?id=<key>#<index>
+		token, page, _ := strings.Cut(values.Get("id"), "#")
+		key = token
+		index, _ = strconv.Atoi(page)
+	case len(segments) == 2:
+		values.Set("_id", segments[1])
+		key = queryKey(resourceType, values)
+	default:
+		key = queryKey(resourceType, values)
+	}
+	// A next page is recorded as a query of the search it continues
+	searched, _, _ := strings.Cut(key, "?")
+	c.queries = append(c.queries, Query{
+		ResourceType: fhirInterface.ResourceType(searched),
+		Uri:          uri,
+		Parameters:   params,
+		Raw:          raw,
+	})
+	pages := c.responses[key]
+	if index >= len(pages) {
+		return nil, fmt.Errorf("fhirtest: no response registered for %s?%s", path, values.Encode())
+	}
+	if pages[index].Err != nil {
+		return nil, pages[index].Err
+	}
+	if len(pages) == 1 {
+		return pages[index].Body, nil
+	}
+	return withPageLinks(pages[index].Body, key, index, len(pages))
+}
+
+// withPageLinks sets the links of the Bundle at index among count pages, so
+// every LoadPage relation leads to the matching page.
+func withPageLinks(body []byte, key string, index int, count int) ([]byte, error) {
+	bundle := map[string]interface{}{}
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return nil, err
+	}
+	pageUrl := func(i int) string {
+		values := url.Values{}
+		values.Set("id", key+"#"+strconv.Itoa(i))
+		return FAKE_BASE_URL + "/_page?" + values.Encode()
+	}
+	links := []interface{}{
+		map[string]interface{}{"relation": fhirInterface.LINK_SELF, "url": pageUrl(index)},
+		map[string]interface{}{"relation": fhirInterface.LINK_FIRST, "url": pageUrl(0)},
+	}
+	if index > 0 {
+		links = append(links, map[string]interface{}{"relation": fhirInterface.LINK_PREVIOUS, "url": pageUrl(index - 1)})
+	}
+	if index+1 < count {
+		links = append(links, map[string]interface{}{"relation": fhirInterface.LINK_NEXT, "url": pageUrl(index + 1)})
+	}
+	bundle["link"] = append(links, map[string]interface{}{"relation": fhirInterface.LINK_LAST, "url": pageUrl(count - 1)})
+	return json.Marshal(bundle)
+}
+
+func queryKey(resourceType fhirInterface.ResourceType, values url.Values) string {
+	// _count depends on the client settings, not on the query
+	values.Del("_count")
+	return string(resourceType) + "?" + values.Encode()
+}
+
+func (c *Client) LoadPage() fhirInterface.PageLoader {
+	return fhirInterface.NewPageLoader(c.Logger, func(cursor fhirInterface.Cursor) fhirInterface.IResourceResult {
+		return models_r4.BundleFromCursor(c, cursor)
+	})
+}
+
+func (c *Client) GetBaseUrl() string {
+	return FAKE_BASE_URL
+}
+
+func (c *Client) GetProfile() fhirInterface.ServerProfile {
+	return c.Profile
+}
+
+func (c *Client) GetLogger() *slog.Logger {
+	return c.Logger
+}
+
+func (c *Client) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	}
+	c.Logger = logger
+}
+
+func (c *Client) GetRaw(uri string, p fhirInterface.UrlParameters) ([]byte, error) {
+	return c.GetRawContext(context.Background(), uri, p)
+}
+
+func (c *Client) GetRawContext(ctx context.Context, uri string, p fhirInterface.UrlParameters) ([]byte, error) {
+	return c.serve(ctx, uri, p, true)
+}
+
+func (c *Client) Get(uri string, p fhirInterface.UrlParameters, resType fhirInterface.ResourceType) (fhirInterface.IResourceResult, error) {
+	return c.GetContext(context.Background(), uri, p, resType)
+}
+
+func (c *Client) GetContext(ctx context.Context, uri string, p fhirInterface.UrlParameters, resType fhirInterface.ResourceType) (fhirInterface.IResourceResult, error) {
+	if resType != fhirInterface.BUNDLE {
+		return nil, fmt.Errorf("fhir: unsupported resource type %q", resType)
+	}
+	body, err := c.serve(ctx, uri, p, false)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &models_r4.BundleResult{
+		Client: c,
+	}
+	if err := json.Unmarshal(body, bundle); err != nil {
+		return nil, err
+	}
+	return bundle, nil
+}
+
+func (c *Client) Search(r fhirInterface.ResourceType) fhirInterface.IResource {
+	switch r {
+	case fhirInterface.ORGANIZATION:
+		return &models_r4.Organization{
+			Client: c,
+		}
+	case fhirInterface.PRACTITIONER_ROLE:
+		return &models_r4.PractitionerRole{
+			Client: c,
+		}
+	case fhirInterface.PRACTITIONER:
+		return &models_r4.Practitioner{
+			Client: c,
+		}
+	}
+	return nil
+}
+
+func (c *Client) SetEntryLimit(limit int) {
+	c.EntryLimit = limit
+}
+
+func (c *Client) SetTimeout(timeout int) {
+	c.Timeout = timeout
+}
+
+func (c *Client) SetRetryPolicy(policy fhirInterface.RetryPolicy) {
+	c.RetryPolicy = policy
+}
+
+// Use, OnRequest and OnResponse are accepted but never called, the fake
+// client sends no HTTP request.
+func (c *Client) Use(middlewares ...fhirInterface.Middleware) {}
+
+func (c *Client) OnRequest(hook func(*http.Request)) {}
+
+func (c *Client) OnResponse(hook func(*http.Response)) {}
@@ -0,0 +1,176 @@
+package fhirtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// organizations is a Bundle page of the organizations with ids.
+func organizations(ids ...string) []byte {
+	entries := []string{}
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf(`{"resource":{"resourceType":"Organization","id":%q}}`, id))
+	}
+	return []byte(`{"resourceType":"Bundle","type":"searchset","entry":[` + strings.Join(entries, ",") + `]}`)
+}
+
+func entryIds(res fhirInterface.IResourceResult) []string {
+	ids := []string{}
+	for _, e := range res.GetEntries() {
+		ids = append(ids, e.GetId())
+	}
+	return ids
+}
+
+var reunion = fhirInterface.FhirAddress{}.StartsWith().Value("974")
+
+func TestClientPages(t *testing.T) {
+	client := NewClient()
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1", "org-2"), organizations("org-3"))
+	ctx := context.Background()
+
+	first, err := client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundleContext(ctx)
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if got := entryIds(first); !reflect.DeepEqual(got, []string{"org-1", "org-2"}) {
+		t.Errorf("first page %v, want org-1 and org-2", got)
+	}
+	second, err := client.LoadPage().NextContext(ctx, first)
+	if err != nil {
+		t.Fatalf("next page: %v", err)
+	}
+	if got := entryIds(second); !reflect.DeepEqual(got, []string{"org-3"}) {
+		t.Errorf("second page %v, want org-3", got)
+	}
+	if second.GetNextLink() != "" {
+		t.Errorf("last page links to %s", second.GetNextLink())
+	}
+	if _, err := client.LoadPage().Next(second).ExecuteBundle(); err == nil {
+		t.Error("Next after the last page succeeded")
+	}
+	if back, err := client.LoadPage().Previous(ctx, second); err != nil || !reflect.DeepEqual(entryIds(back), []string{"org-1", "org-2"}) {
+		t.Errorf("previous page %v, %v", back, err)
+	}
+
+	all := []string{}
+	for entry, err := range client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().All(ctx) {
+		if err != nil {
+			t.Fatalf("All: %v", err)
+		}
+		all = append(all, entry.GetId())
+	}
+	if !reflect.DeepEqual(all, []string{"org-1", "org-2", "org-3"}) {
+		t.Errorf("All %v, want the 3 organizations", all)
+	}
+}
+
+func TestClientRespondError(t *testing.T) {
+	notFound := &fhirInterface.HttpError{StatusCode: 404}
+	client := NewClient()
+	client.RespondError(fhirInterface.ORGANIZATION, reunion, notFound)
+	if _, err := client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundle(); !errors.Is(err, notFound) {
+		t.Errorf("search error %v, want the registered one", err)
+	}
+
+	// Registered after a page, the error fails the next one
+	unavailable := &fhirInterface.HttpError{StatusCode: 503}
+	client.Reset()
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1"))
+	client.RespondError(fhirInterface.ORGANIZATION, reunion, unavailable)
+	ids := []string{}
+	var failure error
+	for entry, err := range client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().All(context.Background()) {
+		if err != nil {
+			failure = err
+			break
+		}
+		ids = append(ids, entry.GetId())
+	}
+	if !reflect.DeepEqual(ids, []string{"org-1"}) || !errors.Is(failure, unavailable) {
+		t.Errorf("All gave %v then %v, want org-1 then the registered error", ids, failure)
+	}
+}
+
+func TestClientQueries(t *testing.T) {
+	client := NewClient()
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1"), organizations("org-2"))
+	first, _ := client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundle()
+	client.LoadPage().NextContext(context.Background(), first)
+	client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnRaw().ExecuteRaw()
+
+	queries := client.Queries()
+	if len(queries) != 3 {
+		t.Fatalf("%d queries recorded, want 3: %+v", len(queries), queries)
+	}
+	for i, q := range queries {
+		if q.ResourceType != fhirInterface.ORGANIZATION {
+			t.Errorf("query %d on %s, want Organization", i, q.ResourceType)
+		}
+	}
+	if got := queries[0].Parameters.BuildUrlValues().Encode(); got != "address-postalcode=974" {
+		t.Errorf("search parameters %s, want address-postalcode=974", got)
+	}
+	if !strings.Contains(queries[1].Uri, "/_page") {
+		t.Errorf("next page query on %s, want the page link", queries[1].Uri)
+	}
+	if queries[0].Raw || !queries[2].Raw {
+		t.Errorf("Raw flags %v and %v, want false then true", queries[0].Raw, queries[2].Raw)
+	}
+}
+
+func TestClientMatching(t *testing.T) {
+	client := NewClient()
+	practitioner := []byte(`{"resourceType":"Practitioner","id":"prat-001"}`)
+	client.Respond(fhirInterface.PRACTITIONER, fhirInterface.Param("_id", "prat-001"), practitioner)
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1"))
+
+	raw, err := client.Search(fhirInterface.PRACTITIONER).ById("prat-001").ReturnRaw().ExecuteRaw()
+	if err != nil || string(raw) != string(practitioner) {
+		t.Errorf("read by id: %s, %v", raw, err)
+	}
+	// _count depends on the client settings, it doesn't tell queries apart
+	counted := reunion
+	counted.Count = "50"
+	if _, err := client.Search(fhirInterface.ORGANIZATION).Where(counted).ReturnBundle().ExecuteBundle(); err != nil {
+		t.Errorf("search with _count: %v", err)
+	}
+	if _, err := client.Search(fhirInterface.ORGANIZATION).Where(fhirInterface.FhirAddress{}.StartsWith().Value("976")).ReturnBundle().ExecuteBundle(); err == nil {
+		t.Error("search without registered response succeeded")
+	}
+	if _, err := client.Search(fhirInterface.PRACTITIONER_ROLE).Where(reunion).ReturnBundle().ExecuteBundle(); err == nil {
+		t.Error("search of another resource type succeeded")
+	}
+}
+
+func TestClientReset(t *testing.T) {
+	client := NewClient()
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1"))
+	client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundle()
+	client.Reset()
+	if queries := client.Queries(); len(queries) != 0 {
+		t.Errorf("%d queries left after Reset", len(queries))
+	}
+	if _, err := client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundle(); err == nil {
+		t.Error("response still registered after Reset")
+	}
+}
+
+func TestClientCancelledContext(t *testing.T) {
+	client := NewClient()
+	client.Respond(fhirInterface.ORGANIZATION, reunion, organizations("org-1"))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle().ExecuteBundleContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("error %v, want context.Canceled", err)
+	}
+	if queries := client.Queries(); len(queries) != 0 {
+		t.Errorf("cancelled query recorded: %+v", queries)
+	}
+}
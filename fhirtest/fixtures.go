@@ -0,0 +1,125 @@
+package fhirtest
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Fixtures is a small extract of the annuaire around La Réunion and Mayotte:
+// organizations, the physiotherapists working there and their roles.
+//
+//go:embed fixtures/*.json
+var Fixtures embed.FS
+
+// NewServerFS starts a server loaded with every .json file of fsys.
+func NewServerFS(fsys fs.FS) (*Server, error) {
+	s := newServer()
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".json") {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		if err := s.Load(data); err != nil {
+			return fmt.Errorf("fhirtest: %s: %w", path, err)
+		}
+		return nil
+	})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// LoadPath loads a JSON file, or every .json file of a directory.
+func (s *Server) LoadPath(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		files, err := filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return err
+		}
+		for _, file := range files {
+			if err := s.LoadPath(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if err := s.Load(data); err != nil {
+		return fmt.Errorf("fhirtest: %s: %w", path, err)
+	}
+	return nil
+}
+
+// Load adds resources given as a single resource, a JSON array of resources
+// or a Bundle, such as a search result saved from the real server. A resource
+// replaces the one of same type and id already loaded.
+func (s *Server) Load(data []byte) error {
+	var parsed interface{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, item := range list(parsed) {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected a resource, got %T", item)
+		}
+		if m["resourceType"] != "Bundle" {
+			if err := s.add(m); err != nil {
+				return err
+			}
+			continue
+		}
+		for _, e := range list(m["entry"]) {
+			e, _ := e.(map[string]interface{})
+			if res, ok := e["resource"].(map[string]interface{}); ok {
+				if err := s.add(res); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Server) add(data map[string]interface{}) error {
+	resourceType, _ := data["resourceType"].(string)
+	id, _ := data["id"].(string)
+	if !isResourceType(resourceType) {
+		return fmt.Errorf("unsupported resourceType %q", resourceType)
+	}
+	if id == "" {
+		return fmt.Errorf("%s without id", resourceType)
+	}
+	res := resource{
+		Type: resourceType,
+		Id:   id,
+		Data: data,
+	}
+	for i, existing := range s.resources[resourceType] {
+		if existing.Id == id {
+			s.resources[resourceType][i] = res
+			return nil
+		}
+	}
+	s.resources[resourceType] = append(s.resources[resourceType], res)
+	return nil
+}
@@ -0,0 +1,318 @@
+// Package recorder records the requests issued through a FHIR client into
+// cassette files, and replays them in tests without network access.
+//
+//	rec, err := recorder.New("testdata/organizations.json", recorder.MODE_AUTO,
+//		recorder.WithRedactHeaders("X-Api-Key"))
+//	defer rec.Stop()
+//	clientFhir := fhir.New(baseUrl, fhir.WithAuth("X-Api-Key", apiKey), fhir.WithMiddleware(rec.Middleware()))
+//
+// Requests are matched on their method, path and normalized query
+// parameters, so the order in which UrlParameters.BuildUrlValues emits them
+// doesn't matter.
+//
+// Only headers are redacted: DEFAULT_REDACTED_HEADERS, those whose name
+// contains key, token, secret or password, and the ones given to
+// WithRedactHeaders. Always pass the API key header of the client to
+// WithRedactHeaders, and check a new cassette before committing it: a
+// credential sent in the query or the body is written as is.
+package recorder
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+type Mode int
+
+const (
+	// MODE_REPLAY serves the cassette and fails on any unknown request.
+	MODE_REPLAY Mode = iota
+	// MODE_RECORD forwards every request and writes the cassette on Stop.
+	MODE_RECORD
+	// MODE_AUTO replays the cassette when it exists and records it otherwise.
+	MODE_AUTO
+)
+
+const REDACTED = "REDACTED"
+
+// DEFAULT_REDACTED_HEADERS are never written to a cassette, whatever the
+// RedactHeaders of the recorder.
+var DEFAULT_REDACTED_HEADERS = []string{"Authorization", "Proxy-Authorization", "Cookie", "Set-Cookie", "ESANTE-API-KEY"}
+
+// credentialWords mark a header as a credential, e.g. X-Api-Key or
+// X-Auth-Token.
+var credentialWords = []string{"key", "token", "secret", "password"}
+
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string              `json:"method"`
+	Path   string              `json:"path"`
+	Query  string              `json:"query"`
+	Header map[string][]string `json:"header,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int                 `json:"statusCode"`
+	Header     map[string][]string `json:"header,omitempty"`
+	Body       string              `json:"body"`
+}
+
+type Recorder struct {
+	Path string
+	Mode Mode
+	// RedactHeaders lists extra headers to hide, e.g. a custom API key header.
+	RedactHeaders []string
+	// IgnoreQueryParameters are left out when matching requests, e.g. _count.
+	IgnoreQueryParameters []string
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+type Option func(*Recorder)
+
+// WithRedactHeaders hides headers from the cassette, starting with the API
+// key header the client sends.
+func WithRedactHeaders(headers ...string) Option {
+	return func(r *Recorder) {
+		r.RedactHeaders = append(r.RedactHeaders, headers...)
+	}
+}
+
+// WithIgnoreQueryParameters leaves parameters out of the matching, e.g.
+// _count.
+func WithIgnoreQueryParameters(parameters ...string) Option {
+	return func(r *Recorder) {
+		r.IgnoreQueryParameters = append(r.IgnoreQueryParameters, parameters...)
+	}
+}
+
+// New loads the cassette at path unless recording. In MODE_AUTO the mode
+// becomes MODE_REPLAY or MODE_RECORD depending on whether the file exists.
+func New(path string, mode Mode, opts ...Option) (*Recorder, error) {
+	r := &Recorder{
+		Path: path,
+		Mode: mode,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	if mode == MODE_AUTO {
+		r.Mode = MODE_RECORD
+		if _, err := os.Stat(path); err == nil {
+			r.Mode = MODE_REPLAY
+		}
+	}
+	if r.Mode == MODE_REPLAY {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &r.cassette); err != nil {
+			return nil, fmt.Errorf("recorder: reading %s: %w", path, err)
+		}
+		r.used = make([]bool, len(r.cassette.Interactions))
+	}
+	return r, nil
+}
+
+// Middleware plugs the recorder into a client, see fhir.WithMiddleware.
+func (r *Recorder) Middleware() fhirInterface.Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			if r.Mode == MODE_REPLAY {
+				return r.replay(req)
+			}
+			return r.record(next, req)
+		})
+	}
+}
+
+// RoundTrip lets the recorder be used as the Transport of an http.Client,
+// recording through http.DefaultTransport.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	return r.Middleware()(http.DefaultTransport).RoundTrip(req)
+}
+
+// Stop writes the cassette when recording.
+func (r *Recorder) Stop() error {
+	if r.Mode != MODE_RECORD {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Keep queries and bodies readable, the cassette is meant to be reviewed
+	var data bytes.Buffer
+	encoder := json.NewEncoder(&data)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r.cassette); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.Path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(r.Path, data.Bytes(), 0o644)
+}
+
+func (r *Recorder) record(next http.RoundTripper, req *http.Request) (*http.Response, error) {
+	res, err := next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Path:   req.URL.Path,
+			Query:  r.normalizeQuery(req.URL.Query()),
+			Header: r.redact(req.Header),
+		},
+		Response: RecordedResponse{
+			StatusCode: res.StatusCode,
+			Header:     r.redact(res.Header),
+			Body:       string(body),
+		},
+	})
+	return res, nil
+}
+
+func (r *Recorder) replay(req *http.Request) (*http.Response, error) {
+	query := r.normalizeQuery(req.URL.Query())
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// Serve interactions in recorded order, then keep serving the last
+	// matching one for requests repeated more often than recorded
+	found := -1
+	for i, interaction := range r.cassette.Interactions {
+		if interaction.Request.Method != req.Method || interaction.Request.Path != req.URL.Path || interaction.Request.Query != query {
+			continue
+		}
+		found = i
+		if !r.used[i] {
+			break
+		}
+	}
+	if found < 0 {
+		return nil, fmt.Errorf("recorder: no interaction recorded in %s for %s %s?%s", r.Path, req.Method, req.URL.Path, query)
+	}
+	r.used[found] = true
+
+	recorded := r.cassette.Interactions[found].Response
+	header := http.Header{}
+	for name, values := range recorded.Header {
+		header[name] = append([]string(nil), values...)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.StatusCode, http.StatusText(recorded.StatusCode)),
+		StatusCode:    recorded.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(recorded.Body)),
+		ContentLength: int64(len(recorded.Body)),
+		Request:       req,
+	}, nil
+}
+
+// Unused returns the recorded interactions never replayed, handy to check a
+// test issued every request it was expected to.
+func (r *Recorder) Unused() []Interaction {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	unused := []Interaction{}
+	for i, used := range r.used {
+		if !used {
+			unused = append(unused, r.cassette.Interactions[i])
+		}
+	}
+	return unused
+}
+
+// normalizeQuery sorts the parameters and their values, dropping the ignored
+// ones.
+func (r *Recorder) normalizeQuery(values url.Values) string {
+	normalized := url.Values{}
+	for name, v := range values {
+		if contains(r.IgnoreQueryParameters, name) {
+			continue
+		}
+		sorted := append([]string(nil), v...)
+		sort.Strings(sorted)
+		normalized[name] = sorted
+	}
+	// Encode sorts by key
+	return normalized.Encode()
+}
+
+func (r *Recorder) redact(header http.Header) map[string][]string {
+	if len(header) == 0 {
+		return nil
+	}
+	redacted := map[string][]string{}
+	for name, values := range header {
+		if containsFold(DEFAULT_REDACTED_HEADERS, name) || containsFold(r.RedactHeaders, name) || isCredential(name) {
+			redacted[name] = []string{REDACTED}
+			continue
+		}
+		redacted[name] = append([]string(nil), values...)
+	}
+	return redacted
+}
+
+func isCredential(header string) bool {
+	header = strings.ToLower(header)
+	for _, word := range credentialWords {
+		if strings.Contains(header, word) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsFold(list []string, value string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
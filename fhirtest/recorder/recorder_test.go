@@ -0,0 +1,193 @@
+package recorder
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fhir "github.com/LGMorgan/go-fhir"
+	"github.com/LGMorgan/go-fhir/fhirtest"
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// newEchoServer answers every request with its query, and counts them.
+func newEchoServer(t *testing.T) (*httptest.Server, *int) {
+	t.Helper()
+	served := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.Header().Set("Set-Cookie", "session=SECRET")
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	t.Cleanup(server.Close)
+	return server, &served
+}
+
+func get(t *testing.T, rec *Recorder, rawUrl string, header http.Header) (string, error) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, rawUrl, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	res, err := (&http.Client{Transport: rec}).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestRecordThenReplayWithoutServer(t *testing.T) {
+	srv, err := fhirtest.NewServerFS(fhirtest.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "cassettes", "organizations.json")
+	params := fhirInterface.FhirAddress{}.StartsWith().Value("974")
+	search := func(rec *Recorder) ([]byte, error) {
+		client := fhir.New(srv.URL,
+			fhir.WithAuth("ESANTE-API-KEY", "SECRET"),
+			fhir.WithServerProfile(fhirInterface.GENERIC_PROFILE),
+			fhir.WithRetryPolicy(fhirInterface.RetryPolicy{}),
+			fhir.WithMiddleware(rec.Middleware()))
+		return client.GetRaw("/Organization", params)
+	}
+
+	rec, err := New(path, MODE_AUTO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode != MODE_RECORD {
+		t.Fatalf("mode %d without a cassette, want MODE_RECORD", rec.Mode)
+	}
+	recorded, err := search(rec)
+	if err != nil {
+		t.Fatalf("recording: %v", err)
+	}
+	if !strings.Contains(string(recorded), "org-974-001") {
+		t.Fatalf("recorded %s, want the organizations of La Réunion", recorded)
+	}
+	if err := rec.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	srv.Close()
+
+	rec, err = New(path, MODE_AUTO)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Mode != MODE_REPLAY {
+		t.Fatalf("mode %d with a cassette, want MODE_REPLAY", rec.Mode)
+	}
+	replayed, err := search(rec)
+	if err != nil {
+		t.Fatalf("replaying: %v", err)
+	}
+	if string(replayed) != string(recorded) {
+		t.Errorf("replayed %s, want %s", replayed, recorded)
+	}
+	if unused := rec.Unused(); len(unused) != 0 {
+		t.Errorf("%d interactions left unused", len(unused))
+	}
+}
+
+func TestReplayNormalizesQuery(t *testing.T) {
+	server, served := newEchoServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, _ := New(path, MODE_RECORD, WithIgnoreQueryParameters("_count"))
+	get(t, rec, server.URL+"/Organization?name=b&address-postalcode=974&name=a&_count=10", nil)
+	rec.Stop()
+
+	rec, err := New(path, MODE_REPLAY, WithIgnoreQueryParameters("_count"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := get(t, rec, server.URL+"/Organization?_count=50&name=a&address-postalcode=974&name=b", nil)
+	if err != nil {
+		t.Fatalf("replaying the reordered query: %v", err)
+	}
+	if body != "name=b&address-postalcode=974&name=a&_count=10" {
+		t.Errorf("replayed %q, want the recorded body", body)
+	}
+	if *served != 1 {
+		t.Errorf("server reached %d times, want only while recording", *served)
+	}
+}
+
+func TestReplayMiss(t *testing.T) {
+	if _, err := New(filepath.Join(t.TempDir(), "missing.json"), MODE_REPLAY); err == nil {
+		t.Error("New succeeded in MODE_REPLAY without a cassette")
+	}
+
+	server, _ := newEchoServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, _ := New(path, MODE_RECORD)
+	get(t, rec, server.URL+"/Organization?name=a", nil)
+	rec.Stop()
+
+	rec, _ = New(path, MODE_REPLAY)
+	for _, rawUrl := range []string{
+		server.URL + "/Organization?name=b",
+		server.URL + "/Practitioner?name=a",
+	} {
+		if _, err := get(t, rec, rawUrl, nil); err == nil || !strings.Contains(err.Error(), "no interaction recorded") {
+			t.Errorf("GET %s: error %v, want no interaction recorded", rawUrl, err)
+		}
+	}
+}
+
+func TestRedaction(t *testing.T) {
+	server, _ := newEchoServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, _ := New(path, MODE_RECORD, WithRedactHeaders("X-Gateway"))
+	get(t, rec, server.URL+"/Organization", http.Header{
+		"Esante-Api-Key": {"SECRET"},
+		"X-Api-Key":      {"SECRET"},
+		"X-Auth-Token":   {"SECRET"},
+		"Authorization":  {"Bearer SECRET"},
+		"X-Gateway":      {"SECRET"},
+		"Accept":         {"application/fhir+json"},
+	})
+	if err := rec.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "SECRET") {
+		t.Errorf("credential written to the cassette:\n%s", data)
+	}
+	if !strings.Contains(string(data), "application/fhir+json") {
+		t.Errorf("Accept header missing from the cassette:\n%s", data)
+	}
+}
+
+func TestUnused(t *testing.T) {
+	server, _ := newEchoServer(t)
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	rec, _ := New(path, MODE_RECORD)
+	get(t, rec, server.URL+"/Organization?page=1", nil)
+	get(t, rec, server.URL+"/Organization?page=2", nil)
+	rec.Stop()
+
+	rec, _ = New(path, MODE_REPLAY)
+	get(t, rec, server.URL+"/Organization?page=1", nil)
+	// Repeated requests keep getting the last matching interaction
+	if body, err := get(t, rec, server.URL+"/Organization?page=1", nil); err != nil || body != "page=1" {
+		t.Errorf("repeated request: %q, %v", body, err)
+	}
+	unused := rec.Unused()
+	if len(unused) != 1 || unused[0].Request.Query != "page=2" {
+		t.Errorf("Unused() = %+v, want the page=2 interaction", unused)
+	}
+}
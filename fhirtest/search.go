@@ -0,0 +1,463 @@
+package fhirtest
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// filter is one search parameter of a query: the resource matches when any
+// of its comma separated Values matches. Repeated parameters are ANDed.
+type filter struct {
+	Name     string
+	Modifier string
+	Values   []string
+}
+
+var (
+	stringModifiers = []string{"", "exact", "contains", "missing"}
+	tokenModifiers  = []string{"", "not", "text", "missing"}
+)
+
+// parameters lists the search parameters of each resource type, with the
+// modifiers they support. :in, :above and :below need a terminology server
+// and are rejected.
+var parameters = map[string]map[string][]string{
+	"Organization": {
+		"_id":                {""},
+		"_lastUpdated":       {""},
+		"identifier":         tokenModifiers,
+		"name":               stringModifiers,
+		"address-postalcode": stringModifiers,
+		"active":             {"", "not", "missing"},
+	},
+	"Practitioner": {
+		"_id":                {""},
+		"_lastUpdated":       {""},
+		"identifier":         tokenModifiers,
+		"name":               stringModifiers,
+		"address-postalcode": stringModifiers,
+		"qualification-code": tokenModifiers,
+		"active":             {"", "not", "missing"},
+	},
+	"PractitionerRole": {
+		"_id":          {""},
+		"_lastUpdated": {""},
+		"identifier":   tokenModifiers,
+		"role":         tokenModifiers,
+		"active":       {"", "not", "missing"},
+	},
+}
+
+type revInclude struct {
+	Type  string
+	Param string
+}
+
+// parseQuery splits a search query into filters, _revinclude and _count.
+func parseQuery(resourceType string, query url.Values) ([]filter, []revInclude, int, error) {
+	filters := []filter{}
+	includes := []revInclude{}
+	count := 0
+	for name, values := range query {
+		switch name {
+		case "_count":
+			c, err := strconv.Atoi(values[len(values)-1])
+			if err != nil || c < 0 {
+				return nil, nil, 0, fmt.Errorf("invalid _count %q", values[len(values)-1])
+			}
+			count = c
+		case "_revinclude":
+			for _, value := range values {
+				include, param, ok := strings.Cut(value, ":")
+				if !ok || !isResourceType(include) {
+					return nil, nil, 0, fmt.Errorf("unsupported _revinclude %q", value)
+				}
+				includes = append(includes, revInclude{
+					Type:  include,
+					Param: param,
+				})
+			}
+		default:
+			param, modifier, _ := strings.Cut(name, ":")
+			supported, ok := parameters[resourceType][param]
+			if !ok {
+				return nil, nil, 0, fmt.Errorf("unsupported search parameter %q for %s", name, resourceType)
+			}
+			if !slices.Contains(supported, modifier) {
+				return nil, nil, 0, fmt.Errorf("unsupported modifier %q for %s", modifier, param)
+			}
+			for _, value := range values {
+				if modifier == "missing" && value != "true" && value != "false" {
+					return nil, nil, 0, fmt.Errorf("invalid %s %q", name, value)
+				}
+				if param == "_lastUpdated" {
+					for _, date := range splitEscaped(value, ',') {
+						if _, _, _, err := dateRange(unescape(date)); err != nil {
+							return nil, nil, 0, err
+						}
+					}
+				}
+				filters = append(filters, filter{
+					Name:     param,
+					Modifier: modifier,
+					Values:   splitEscaped(value, ','),
+				})
+			}
+		}
+	}
+	return filters, includes, count, nil
+}
+
+func matchesAll(res resource, filters []filter) bool {
+	for _, f := range filters {
+		if !matches(res, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matches(res resource, f filter) bool {
+	switch f.Name {
+	case "_lastUpdated":
+		meta, _ := res.Data["meta"].(map[string]interface{})
+		lastUpdated, _ := meta["lastUpdated"].(string)
+		instant, err := time.Parse(time.RFC3339, lastUpdated)
+		if err != nil {
+			return false
+		}
+		return slices.ContainsFunc(f.Values, func(value string) bool {
+			return matchDate(instant, unescape(value))
+		})
+	case "name", "address-postalcode":
+		candidates := stringValues(res, f.Name)
+		if f.Modifier == "missing" {
+			return (len(candidates) == 0) == (f.Values[0] == "true")
+		}
+		return slices.ContainsFunc(f.Values, func(value string) bool {
+			return matchString(candidates, unescape(value), f.Modifier)
+		})
+	default:
+		candidates, displays := tokenValues(res, f.Name)
+		switch f.Modifier {
+		case "missing":
+			return (len(candidates) == 0) == (f.Values[0] == "true")
+		case "text":
+			return slices.ContainsFunc(f.Values, func(value string) bool {
+				return matchString(displays, unescape(value), "")
+			})
+		}
+		ok := slices.ContainsFunc(f.Values, func(value string) bool {
+			return matchToken(candidates, value)
+		})
+		// :not keeps the resources matching none of the values
+		return ok != (f.Modifier == "not")
+	}
+}
+
+// stringValues reads the values a string parameter searches.
+func stringValues(res resource, name string) []string {
+	if name == "name" {
+		return names(res.Data)
+	}
+	return fieldStrings(res.Data["address"], "postalCode")
+}
+
+// tokenValues reads the codes a token parameter searches, and the texts
+// :text searches.
+func tokenValues(res resource, name string) ([]coding, []string) {
+	switch name {
+	case "_id":
+		return []coding{{Code: res.Id}}, nil
+	case "active":
+		active, isBool := res.Data["active"].(bool)
+		if !isBool {
+			return nil, nil
+		}
+		return []coding{{Code: strconv.FormatBool(active)}}, nil
+	case "identifier":
+		values := []coding{}
+		types := []interface{}{}
+		for _, identifier := range list(res.Data["identifier"]) {
+			if i, isMap := identifier.(map[string]interface{}); isMap {
+				system, _ := i["system"].(string)
+				value, _ := i["value"].(string)
+				values = append(values, coding{
+					System: system,
+					Code:   value,
+				})
+				types = append(types, i["type"])
+			}
+		}
+		return values, texts(types)
+	}
+	concepts := res.Data["code"]
+	if name == "qualification-code" {
+		codes := []interface{}{}
+		for _, qualification := range list(res.Data["qualification"]) {
+			if q, isMap := qualification.(map[string]interface{}); isMap {
+				codes = append(codes, q["code"])
+			}
+		}
+		concepts = codes
+	}
+	return codings(concepts), texts(concepts)
+}
+
+// references tells whether res points to match through the Param reference.
+func (i revInclude) references(res resource, match resource) bool {
+	target := match.Type + "/" + match.Id
+	for _, ref := range fieldStrings(res.Data[i.Param], "reference") {
+		if ref == target || strings.HasSuffix(ref, "/"+target) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchString follows the FHIR string search: case and accent insensitive,
+// matching the start of the value, or any part of it with :contains. :exact
+// matches the whole value as is.
+func matchString(candidates []string, value string, modifier string) bool {
+	for _, candidate := range candidates {
+		var ok bool
+		switch modifier {
+		case "exact":
+			ok = candidate == value
+		case "contains":
+			ok = strings.Contains(normalize(candidate), normalize(value))
+		default:
+			ok = strings.HasPrefix(normalize(candidate), normalize(value))
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dateRange splits a date search value, [prefix]date, into its prefix, eq by
+// default, and the period [start, end) the date covers at its precision.
+func dateRange(value string) (string, time.Time, time.Time, error) {
+	prefix := "eq"
+	if len(value) > 2 && slices.Contains(datePrefixes, value[:2]) {
+		prefix, value = value[:2], value[2:]
+	}
+	for _, layout := range dateLayouts {
+		start, err := time.Parse(layout.Layout, value)
+		if err == nil {
+			return prefix, start, layout.End(start), nil
+		}
+	}
+	return "", time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+var datePrefixes = []string{"eq", "ne", "gt", "lt", "ge", "le", "sa", "eb", "ap"}
+
+// dateLayouts are the FHIR partial dates. Those without time zone are taken
+// as UTC.
+var dateLayouts = []struct {
+	Layout string
+	End    func(time.Time) time.Time
+}{
+	{"2006", func(t time.Time) time.Time { return t.AddDate(1, 0, 0) }},
+	{"2006-01", func(t time.Time) time.Time { return t.AddDate(0, 1, 0) }},
+	{"2006-01-02", func(t time.Time) time.Time { return t.AddDate(0, 0, 1) }},
+	{"2006-01-02T15:04Z07:00", func(t time.Time) time.Time { return t.Add(time.Minute) }},
+	{time.RFC3339, func(t time.Time) time.Time { return t.Add(time.Second) }},
+}
+
+// matchDate follows the FHIR date search, comparing instant to the period of
+// value. ap accepts 10% of the distance between now and value around it.
+func matchDate(instant time.Time, value string) bool {
+	prefix, start, end, err := dateRange(value)
+	if err != nil {
+		return false
+	}
+	within := !instant.Before(start) && instant.Before(end)
+	switch prefix {
+	case "ne":
+		return !within
+	case "gt", "sa":
+		return !instant.Before(end)
+	case "lt", "eb":
+		return instant.Before(start)
+	case "ge":
+		return !instant.Before(start)
+	case "le":
+		return instant.Before(end)
+	case "ap":
+		margin := time.Since(start).Abs() / 10
+		return !instant.Before(start.Add(-margin)) && instant.Before(end.Add(margin))
+	}
+	return within
+}
+
+type coding struct {
+	System string
+	Code   string
+}
+
+// matchToken follows the FHIR token search: code, system|code, |code or system|.
+func matchToken(candidates []coding, value string) bool {
+	parts := splitEscaped(value, '|')
+	system, code, hasSystem := "", unescape(parts[0]), len(parts) > 1
+	if hasSystem {
+		system, code = unescape(parts[0]), unescape(strings.Join(parts[1:], "|"))
+	}
+	for _, c := range candidates {
+		switch {
+		case !hasSystem && c.Code == code:
+			return true
+		case hasSystem && code == "" && c.System == system:
+			return true
+		case hasSystem && code != "" && c.System == system && c.Code == code:
+			return true
+		}
+	}
+	return false
+}
+
+// splitEscaped splits s on the separators not escaped with a \, keeping the
+// escapes.
+func splitEscaped(s string, separator rune) []string {
+	parts := []string{}
+	var part strings.Builder
+	escaped := false
+	for _, r := range s {
+		switch {
+		case escaped:
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == separator:
+			parts = append(parts, part.String())
+			part.Reset()
+			continue
+		}
+		part.WriteRune(r)
+	}
+	return append(parts, part.String())
+}
+
+// unescape removes the \ escaping the FHIR separators of a search value.
+func unescape(s string) string {
+	var b strings.Builder
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		escaped = false
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func normalize(s string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(s) {
+		if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(unicode.ToLower(r))
+		}
+	}
+	return b.String()
+}
+
+// names reads a name given either as a string (Organization) or as
+// HumanNames (Practitioner).
+func names(data map[string]interface{}) []string {
+	if name, ok := data["name"].(string); ok {
+		return []string{name}
+	}
+	values := []string{}
+	for _, name := range list(data["name"]) {
+		n, ok := name.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		values = append(values, fieldStrings(n, "family", "text")...)
+		for _, part := range append(list(n["given"]), list(n["prefix"])...) {
+			if s, ok := part.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// codings gathers the codings of CodeableConcepts.
+func codings(concepts interface{}) []coding {
+	values := []coding{}
+	for _, concept := range list(concepts) {
+		c, ok := concept.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, cc := range list(c["coding"]) {
+			if m, ok := cc.(map[string]interface{}); ok {
+				system, _ := m["system"].(string)
+				code, _ := m["code"].(string)
+				values = append(values, coding{
+					System: system,
+					Code:   code,
+				})
+			}
+		}
+	}
+	return values
+}
+
+// texts gathers the texts of CodeableConcepts and the displays of their
+// codings.
+func texts(concepts interface{}) []string {
+	values := []string{}
+	for _, concept := range list(concepts) {
+		c, ok := concept.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if text, ok := c["text"].(string); ok {
+			values = append(values, text)
+		}
+		values = append(values, fieldStrings(c["coding"], "display")...)
+	}
+	return values
+}
+
+// fieldStrings reads the string fields of an object or of a list of objects.
+func fieldStrings(value interface{}, fields ...string) []string {
+	values := []string{}
+	for _, item := range list(value) {
+		m, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, field := range fields {
+			if s, ok := m[field].(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	return values
+}
+
+// list treats a single value as a list of one.
+func list(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
@@ -0,0 +1,312 @@
+// Package fhirtest helps testing code built on the client without network
+// access: Server is a fake FHIR server backed by JSON fixtures.
+//
+//	srv, err := fhirtest.NewServerFS(fhirtest.Fixtures)
+//	defer srv.Close()
+//	clientFhir := fhir.New(srv.URL, fhir.WithAuth("ESANTE-API-KEY", "test"))
+package fhirtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+const DEFAULT_PAGE_SIZE = 20
+
+// Server serves Organization, Practitioner and PractitionerRole resources
+// from memory. It answers searches on the parameters the builders emit for
+// each resource type, with their modifiers but the terminology ones (:in,
+// :above, :below), reads by id, and pages the results the esante or HAPI way
+// depending on Pagination. Any other parameter, or a parameter of another
+// resource type, is rejected with a 400, so a test notices a query the real
+// server wouldn't understand either.
+type Server struct {
+	*httptest.Server
+	// Pagination tells how page links are built: /_page?id=<token> for
+	// PAGINATION_ESANTE (the default), ?_getpages=<id>&_pageId=<offset>
+	// otherwise.
+	Pagination fhirInterface.PaginationStrategy
+	// PageSize is used when the request has no _count.
+	PageSize int
+
+	mu        sync.Mutex
+	resources map[string][]resource
+	searches  map[string]*search
+	pages     map[string]page
+}
+
+type resource struct {
+	Type string
+	Id   string
+	Data map[string]interface{}
+}
+
+// search holds the results of a search, kept for its next pages.
+type search struct {
+	Id       string
+	Results  []resource
+	Includes []revInclude
+	Count    int
+}
+
+// page is what an esante /_page?id= token points to.
+type page struct {
+	Search *search
+	Offset int
+}
+
+// NewServer starts a server loaded with the fixtures, JSON files or
+// directories of JSON files. See Load for the accepted formats.
+func NewServer(fixtures ...string) (*Server, error) {
+	s := newServer()
+	for _, fixture := range fixtures {
+		if err := s.LoadPath(fixture); err != nil {
+			s.Close()
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func newServer() *Server {
+	s := &Server{
+		Pagination: fhirInterface.PAGINATION_ESANTE,
+		PageSize:   DEFAULT_PAGE_SIZE,
+		resources:  map[string][]resource{},
+		searches:   map[string]*search{},
+		pages:      map[string]page{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeOutcome(w, http.StatusMethodNotAllowed, "not-supported", r.Method+" is not supported")
+		return
+	}
+	query := r.URL.Query()
+	// Find where the FHIR path starts, whatever the base path, e.g. /v2
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	for i, segment := range segments {
+		base := s.baseUrl(segments[:i])
+		switch {
+		case segment == "_page":
+			s.servePage(w, base, query.Get("id"))
+			return
+		case isResourceType(segment) && i == len(segments)-1:
+			s.serveSearch(w, base, segment, query)
+			return
+		case isResourceType(segment) && i == len(segments)-2:
+			s.serveRead(w, segment, segments[i+1])
+			return
+		}
+	}
+	if query.Get("_getpages") != "" {
+		offset, _ := strconv.Atoi(query.Get("_pageId"))
+		s.serveSearchPage(w, s.baseUrl(segments), query.Get("_getpages"), offset)
+		return
+	}
+	writeOutcome(w, http.StatusNotFound, "not-found", "unknown path "+r.URL.Path)
+}
+
+// baseUrl is the base URL the client used, next links are built on it.
+func (s *Server) baseUrl(segments []string) string {
+	base := s.URL
+	for _, segment := range segments {
+		if segment != "" {
+			base += "/" + segment
+		}
+	}
+	return base
+}
+
+func (s *Server) serveRead(w http.ResponseWriter, resourceType string, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, res := range s.resources[resourceType] {
+		if res.Id == id {
+			writeJson(w, http.StatusOK, res.Data)
+			return
+		}
+	}
+	writeOutcome(w, http.StatusNotFound, "not-found", resourceType+"/"+id+" is not known")
+}
+
+func (s *Server) serveSearch(w http.ResponseWriter, base string, resourceType string, query url.Values) {
+	filters, includes, count, err := parseQuery(resourceType, query)
+	if err != nil {
+		writeOutcome(w, http.StatusBadRequest, "not-supported", err.Error())
+		return
+	}
+	if count <= 0 {
+		count = s.PageSize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found := &search{
+		Id:       newToken(),
+		Includes: includes,
+		Count:    count,
+	}
+	for _, res := range s.resources[resourceType] {
+		if matchesAll(res, filters) {
+			found.Results = append(found.Results, res)
+		}
+	}
+	s.searches[found.Id] = found
+	writeJson(w, http.StatusOK, s.bundle(base, found, 0))
+}
+
+func (s *Server) servePage(w http.ResponseWriter, base string, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pages[token]
+	if !ok {
+		writeOutcome(w, http.StatusGone, "not-found", "page "+token+" has expired or never existed")
+		return
+	}
+	writeJson(w, http.StatusOK, s.bundle(base, p.Search, p.Offset))
+}
+
+func (s *Server) serveSearchPage(w http.ResponseWriter, base string, searchId string, offset int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	found, ok := s.searches[searchId]
+	if !ok {
+		writeOutcome(w, http.StatusGone, "not-found", "search "+searchId+" has expired or never existed")
+		return
+	}
+	writeJson(w, http.StatusOK, s.bundle(base, found, offset))
+}
+
+// bundle renders the page of found starting at offset, with the resources
+// included by _revinclude and the links to the other pages.
+func (s *Server) bundle(base string, found *search, offset int) map[string]interface{} {
+	if offset < 0 || offset > len(found.Results) {
+		offset = len(found.Results)
+	}
+	end := offset + found.Count
+	if end > len(found.Results) {
+		end = len(found.Results)
+	}
+	entries := []interface{}{}
+	for _, res := range found.Results[offset:end] {
+		entries = append(entries, entry(base, res, "match"))
+	}
+	for _, include := range found.Includes {
+		for _, res := range s.resources[include.Type] {
+			for _, match := range found.Results[offset:end] {
+				if include.references(res, match) {
+					entries = append(entries, entry(base, res, "include"))
+					break
+				}
+			}
+		}
+	}
+
+	link := func(relation string, offset int) map[string]interface{} {
+		return map[string]interface{}{
+			"relation": relation,
+			"url":      s.pageLink(base, found, offset),
+		}
+	}
+	last := 0
+	if found.Count > 0 && len(found.Results) > 0 {
+		last = (len(found.Results) - 1) / found.Count * found.Count
+	}
+	links := []interface{}{
+		link(fhirInterface.LINK_SELF, offset),
+		link(fhirInterface.LINK_FIRST, 0),
+	}
+	if offset > 0 {
+		previous := offset - found.Count
+		if previous < 0 {
+			previous = 0
+		}
+		links = append(links, link(fhirInterface.LINK_PREVIOUS, previous))
+	}
+	if end < len(found.Results) {
+		links = append(links, link(fhirInterface.LINK_NEXT, end))
+	}
+	links = append(links, link(fhirInterface.LINK_LAST, last))
+	return map[string]interface{}{
+		"resourceType": "Bundle",
+		"id":           newToken(),
+		"type":         "searchset",
+		"total":        len(found.Results),
+		"link":         links,
+		"entry":        entries,
+	}
+}
+
+func (s *Server) pageLink(base string, found *search, offset int) string {
+	if s.Pagination == fhirInterface.PAGINATION_ESANTE {
+		token := newToken()
+		s.pages[token] = page{
+			Search: found,
+			Offset: offset,
+		}
+		return base + "/_page?id=" + token
+	}
+	values := url.Values{}
+	values.Set("_getpages", found.Id)
+	values.Set("_pageId", strconv.Itoa(offset))
+	values.Set("_bundletype", "searchset")
+	return base + "?" + values.Encode()
+}
+
+func entry(base string, res resource, mode string) map[string]interface{} {
+	return map[string]interface{}{
+		"fullUrl":  base + "/" + res.Type + "/" + res.Id,
+		"resource": res.Data,
+		"search": map[string]interface{}{
+			"mode": mode,
+		},
+	}
+}
+
+func isResourceType(name string) bool {
+	switch fhirInterface.ResourceType(name) {
+	case fhirInterface.ORGANIZATION, fhirInterface.PRACTITIONER, fhirInterface.PRACTITIONER_ROLE:
+		return true
+	}
+	return false
+}
+
+func newToken() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
+
+func writeJson(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/fhir+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeOutcome(w http.ResponseWriter, status int, code string, diagnostics string) {
+	writeJson(w, status, map[string]interface{}{
+		"resourceType": "OperationOutcome",
+		"issue": []interface{}{
+			map[string]interface{}{
+				"severity":    "error",
+				"code":        code,
+				"diagnostics": diagnostics,
+			},
+		},
+	})
+}
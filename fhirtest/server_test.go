@@ -0,0 +1,285 @@
+package fhirtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+const professionSante = "https://mos.esante.gouv.fr/NOS/TRE_G15-ProfessionSante/FHIR/TRE-G15-ProfessionSante"
+
+type testBundle struct {
+	Total int `json:"total"`
+	Link  []struct {
+		Relation string `json:"relation"`
+		Url      string `json:"url"`
+	} `json:"link"`
+	Entry []struct {
+		FullUrl  string `json:"fullUrl"`
+		Resource struct {
+			Id string `json:"id"`
+		} `json:"resource"`
+		Search struct {
+			Mode string `json:"mode"`
+		} `json:"search"`
+	} `json:"entry"`
+}
+
+// ids lists the ids of the entries found with mode, match or include.
+func (b testBundle) ids(mode string) []string {
+	ids := []string{}
+	for _, e := range b.Entry {
+		if e.Search.Mode == mode {
+			ids = append(ids, e.Resource.Id)
+		}
+	}
+	return ids
+}
+
+func (b testBundle) next() string {
+	for _, l := range b.Link {
+		if l.Relation == fhirInterface.LINK_NEXT {
+			return l.Url
+		}
+	}
+	return ""
+}
+
+func newFixturesServer(t *testing.T) *Server {
+	t.Helper()
+	srv, err := NewServerFS(Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get decodes the bundle at rawUrl, failing unless the server answers 200.
+func get(t *testing.T, rawUrl string) testBundle {
+	t.Helper()
+	res, err := http.Get(rawUrl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET %s: status %d", rawUrl, res.StatusCode)
+	}
+	var bundle testBundle
+	if err := json.NewDecoder(res.Body).Decode(&bundle); err != nil {
+		t.Fatal(err)
+	}
+	return bundle
+}
+
+func status(t *testing.T, method string, rawUrl string) int {
+	t.Helper()
+	req, _ := http.NewRequest(method, rawUrl, nil)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	return res.StatusCode
+}
+
+func TestSearch(t *testing.T) {
+	srv := newFixturesServer(t)
+	tests := []struct {
+		name  string
+		query string
+		want  []string
+	}{
+		{"postal code prefix", "/Organization?address-postalcode=974", []string{"org-974-001", "org-974-002", "org-974-003"}},
+		{"and of parameters", "/Organization?address-postalcode=974&active=true", []string{"org-974-001", "org-974-002"}},
+		{"or of values", "/Organization?address-postalcode=97410,976", []string{"org-974-002", "org-976-001"}},
+		{"and of the same parameter", "/Organization?address-postalcode=974&address-postalcode=9741", []string{"org-974-002"}},
+		{"name prefix ignoring case", "/Organization?name=cabinet%20kine", []string{"org-974-002"}},
+		{"name ignoring accents", "/Organization?name=centre%20medical", []string{"org-750-001"}},
+		{"name contains", "/Organization?name:contains=kinesitherapie", []string{"org-974-001", "org-976-001"}},
+		{"name exact", "/Organization?name:exact=PHARMACIE%20DU%20PORT", []string{"org-974-003"}},
+		{"name exact is case sensitive", "/Organization?name:exact=pharmacie%20du%20port", []string{}},
+		{"escaped comma is part of the value", "/Organization?name=CABINET%5C,PAYET", []string{}},
+		{"id", "/Organization?_id=org-976-001", []string{"org-976-001"}},
+		{"identifier with system", "/Organization?identifier=https://finess.esante.gouv.fr%7C970400002", []string{"org-974-002"}},
+		{"identifier missing", "/Organization?identifier:missing=true", []string{"org-750-001"}},
+		{"address missing", "/Practitioner?address-postalcode:missing=false&_id=prat-001", []string{}},
+		{"last updated year", "/Organization?_lastUpdated=2024", []string{"org-974-002", "org-974-003"}},
+		{"last updated ge", "/Organization?_lastUpdated=ge2025", []string{"org-976-001", "org-750-001"}},
+		{"last updated range", "/Organization?_lastUpdated=gt2023&_lastUpdated=lt2024-06", []string{"org-974-002"}},
+		{"last updated or", "/Organization?_lastUpdated=lt2024,ge2025-09", []string{"org-974-001", "org-750-001"}},
+		{"active not", "/Organization?active:not=true", []string{"org-974-003"}},
+		{"inactive", "/PractitionerRole?active=false", []string{"role-005"}},
+		{"active missing", "/Organization?active:missing=true", []string{}},
+		{"active present", "/Organization?active:missing=false&address-postalcode=974", []string{"org-974-001", "org-974-002", "org-974-003"}},
+		{"role code", "/PractitionerRole?role=70", []string{"role-001", "role-002", "role-003", "role-004"}},
+		{"role system and code", "/PractitionerRole?role=" + professionSante + "%7C60", []string{"role-005"}},
+		{"role not", "/PractitionerRole?role:not=70", []string{"role-005", "role-006"}},
+		{"role and", "/PractitionerRole?role=70&role=FON-LIB&active=true", []string{"role-001", "role-002", "role-003", "role-004"}},
+		{"qualification code", "/Practitioner?qualification-code=60,10", []string{"prat-004", "prat-005"}},
+		{"practitioner name", "/Practitioner?name=payet", []string{"prat-002"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := get(t, srv.URL+tt.query).ids("match"); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GET %s = %v, want %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRevInclude(t *testing.T) {
+	srv := newFixturesServer(t)
+	bundle := get(t, srv.URL+"/Organization?_id=org-974-001&_revinclude=PractitionerRole:organization")
+	if got := bundle.ids("match"); !reflect.DeepEqual(got, []string{"org-974-001"}) {
+		t.Errorf("matches %v, want org-974-001", got)
+	}
+	if got := bundle.ids("include"); !reflect.DeepEqual(got, []string{"role-001", "role-002"}) {
+		t.Errorf("includes %v, want role-001 and role-002", got)
+	}
+}
+
+func TestPaging(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination fhirInterface.PaginationStrategy
+		query      string
+		base       string
+		param      string
+		pages      []int
+	}{
+		{"esante with the page size", fhirInterface.PAGINATION_ESANTE, "/Organization", "/_page?", "id=", []int{2, 2, 1}},
+		{"esante with _count", fhirInterface.PAGINATION_ESANTE, "/Organization?_count=4", "/_page?", "id=", []int{4, 1}},
+		{"hapi with the page size", fhirInterface.PAGINATION_HAPI, "/Organization", "?", "_getpages=", []int{2, 2, 1}},
+		{"under a base path", fhirInterface.PAGINATION_HAPI, "/fhir/v2/PractitionerRole?_count=5", "/fhir/v2?", "_getpages=", []int{5, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newFixturesServer(t)
+			srv.Pagination = tt.pagination
+			srv.PageSize = 2
+			pages := []int{}
+			ids := map[string]bool{}
+			bundle := get(t, srv.URL+tt.query)
+			for {
+				pages = append(pages, len(bundle.ids("match")))
+				for _, id := range bundle.ids("match") {
+					ids[id] = true
+				}
+				next := bundle.next()
+				if next == "" {
+					break
+				}
+				if !strings.HasPrefix(next, srv.URL+tt.base) || !strings.Contains(next, tt.param) {
+					t.Fatalf("next link %s, want %s%s...%s", next, srv.URL, tt.base, tt.param)
+				}
+				bundle = get(t, next)
+			}
+			if !reflect.DeepEqual(pages, tt.pages) {
+				t.Errorf("pages of %v entries, want %v", pages, tt.pages)
+			}
+			if len(ids) != bundle.Total {
+				t.Errorf("%d distinct entries, want the %d found", len(ids), bundle.Total)
+			}
+		})
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv := newFixturesServer(t)
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		want   int
+	}{
+		{"read", http.MethodGet, "/Organization/org-974-001", http.StatusOK},
+		{"read under a base path", http.MethodGet, "/fhir/v2/Practitioner/prat-001", http.StatusOK},
+		{"read unknown id", http.MethodGet, "/Organization/org-000", http.StatusNotFound},
+		{"unknown path", http.MethodGet, "/Patient", http.StatusNotFound},
+		{"unsupported method", http.MethodPost, "/Organization", http.StatusMethodNotAllowed},
+		{"unsupported parameter", http.MethodGet, "/Organization?telecom=0262", http.StatusBadRequest},
+		{"parameter of another resource type", http.MethodGet, "/Organization?role=70", http.StatusBadRequest},
+		{"qualification code on a role", http.MethodGet, "/PractitionerRole?qualification-code=70", http.StatusBadRequest},
+		{"terminology modifier", http.MethodGet, "/PractitionerRole?role:in=http://vs", http.StatusBadRequest},
+		{"string modifier on a token", http.MethodGet, "/PractitionerRole?role:exact=70", http.StatusBadRequest},
+		{"invalid missing", http.MethodGet, "/Organization?name:missing=maybe", http.StatusBadRequest},
+		{"invalid date", http.MethodGet, "/Organization?_lastUpdated=ge2024-13", http.StatusBadRequest},
+		{"invalid count", http.MethodGet, "/Organization?_count=-1", http.StatusBadRequest},
+		{"invalid revinclude", http.MethodGet, "/Organization?_revinclude=Patient:organization", http.StatusBadRequest},
+		{"unknown esante page", http.MethodGet, "/_page?id=unknown", http.StatusGone},
+		{"unknown hapi search", http.MethodGet, "/?_getpages=unknown&_pageId=20", http.StatusGone},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status(t, tt.method, srv.URL+tt.path); got != tt.want {
+				t.Errorf("%s %s: status %d, want %d", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	srv, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	formats := []string{
+		`{"resourceType":"Organization","id":"single","name":"SINGLE"}`,
+		`[{"resourceType":"Organization","id":"array-1"},{"resourceType":"Practitioner","id":"array-2"}]`,
+		`{"resourceType":"Bundle","entry":[{"resource":{"resourceType":"Organization","id":"bundled"}}]}`,
+		// Replaces the first one
+		`{"resourceType":"Organization","id":"single","name":"REPLACED"}`,
+	}
+	for _, data := range formats {
+		if err := srv.Load([]byte(data)); err != nil {
+			t.Fatalf("Load(%s): %v", data, err)
+		}
+	}
+	if got := get(t, srv.URL+"/Organization").ids("match"); len(got) != 3 {
+		t.Errorf("organizations %v, want single, array-1 and bundled", got)
+	}
+	if got := get(t, srv.URL+"/Organization?name:exact=REPLACED").ids("match"); !reflect.DeepEqual(got, []string{"single"}) {
+		t.Errorf("replaced organization not found: %v", got)
+	}
+
+	for _, data := range []string{
+		`{"resourceType":"Patient","id":"p"}`,
+		`{"resourceType":"Organization"}`,
+		`[1]`,
+		`{`,
+	} {
+		if err := srv.Load([]byte(data)); err == nil {
+			t.Errorf("Load(%s) succeeded", data)
+		}
+	}
+}
+
+func TestNewServerFromPaths(t *testing.T) {
+	srv, err := NewServer("fixtures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if got := get(t, srv.URL+"/PractitionerRole").Total; got != 6 {
+		t.Errorf("%d roles loaded from the directory, want 6", got)
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.json")
+	os.WriteFile(invalid, []byte(`{"resourceType":"Patient","id":"p"}`), 0o644)
+	for _, path := range []string{invalid, filepath.Join(t.TempDir(), "missing.json")} {
+		if srv, err := NewServer(path); err == nil {
+			srv.Close()
+			t.Errorf("NewServer(%s) succeeded", path)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 module github.com/LGMorgan/go-fhir
 
-go 1.21.0
+go 1.23
 
 require (
 	github.com/joho/godotenv v1.5.1
 	golang.org/x/text v0.15.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
+
+require golang.org/x/crypto v0.11.0 // indirect
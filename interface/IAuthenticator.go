@@ -0,0 +1,9 @@
+package fhirInterface
+
+import "net/http"
+
+type IAuthenticator interface {
+	// Authenticate adds the credentials to req before it is sent. It is called
+	// again on every retry, so expired tokens can be refreshed in between.
+	Authenticate(req *http.Request) error
+}
@@ -0,0 +1,18 @@
+package fhirInterface
+
+import "time"
+
+// CacheEntry is a response body kept with the validators needed to
+// revalidate it.
+type CacheEntry struct {
+	Body         []byte
+	ETag         string
+	LastModified string
+	StoredAt     time.Time
+}
+
+type ICache interface {
+	Get(key string) (CacheEntry, bool)
+	Set(key string, entry CacheEntry)
+	Delete(key string)
+}
@@ -1,13 +1,26 @@
 package fhirInterface
 
+import (
+	"context"
+	"log/slog"
+	"net/http"
+)
+
 type IClient interface {
-	LoadPage() struct {
-		Next func(IResourceResult) IRequest
-	}
+	LoadPage() PageLoader
 	GetBaseUrl() string
+	GetProfile() ServerProfile
+	GetLogger() *slog.Logger
+	SetLogger(logger *slog.Logger)
 	GetRaw(uri string, p UrlParameters) ([]byte, error)
+	GetRawContext(ctx context.Context, uri string, p UrlParameters) ([]byte, error)
 	Get(uri string, p UrlParameters, resType ResourceType) (IResourceResult, error)
+	GetContext(ctx context.Context, uri string, p UrlParameters, resType ResourceType) (IResourceResult, error)
 	Search(resourceName ResourceType) IResource
 	SetEntryLimit(limit int)
 	SetTimeout(timeout int)
+	SetRetryPolicy(policy RetryPolicy)
+	Use(middlewares ...Middleware)
+	OnRequest(hook func(*http.Request))
+	OnResponse(hook func(*http.Response))
 }
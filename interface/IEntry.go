@@ -2,7 +2,7 @@ package fhirInterface
 
 type IEntry interface {
 	GetId() string
+	GetResourceType() string
 	GetPractitionerReference() string
 	GetOrganizationReference() string
-	GetPractitionerRoleReference() string
 }
@@ -0,0 +1,13 @@
+package fhirInterface
+
+import (
+	"context"
+	"net/http"
+)
+
+type IRateLimiter interface {
+	// Wait blocks until a request may be sent or ctx is done.
+	Wait(ctx context.Context) error
+	// Observe lets the limiter adapt to the rate-limit headers of a response.
+	Observe(res *http.Response)
+}
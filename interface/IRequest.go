@@ -1,5 +1,19 @@
 package fhirInterface
 
+import (
+	"context"
+	"iter"
+)
+
 type IRequest interface {
+	// Deprecated: Execute hides errors behind a nil result, use ExecuteBundle or ExecuteRaw.
 	Execute() interface{}
+	ExecuteContext(ctx context.Context) interface{}
+	ExecuteBundle() (IResourceResult, error)
+	ExecuteBundleContext(ctx context.Context) (IResourceResult, error)
+	ExecuteRaw() ([]byte, error)
+	ExecuteRawContext(ctx context.Context) ([]byte, error)
+	All(ctx context.Context, opts ...IterateOption) iter.Seq2[IEntry, error]
+	Pages(ctx context.Context, opts ...IterateOption) iter.Seq2[IResourceResult, error]
+	Stream(ctx context.Context, opts ...IterateOption) <-chan EntryResult
 }
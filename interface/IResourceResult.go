@@ -1,7 +1,21 @@
 package fhirInterface
 
+// Bundle link relations, see IResourceResult.GetLink.
+const (
+	LINK_SELF     = "self"
+	LINK_FIRST    = "first"
+	LINK_PREVIOUS = "previous"
+	LINK_NEXT     = "next"
+	LINK_LAST     = "last"
+)
+
 type IResourceResult interface {
 	GetId() string
+	GetLink(relation string) string
 	GetNextLink() string
+	GetEntries() []IEntry
+	GetOutcome() error
+	Cursor() Cursor
+	MakeRequestPage(relation string) (IRequest, error)
 	MakeRequestNextPage() (IRequest, error)
 }
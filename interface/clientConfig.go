@@ -0,0 +1,44 @@
+package fhirInterface
+
+import (
+	"crypto/tls"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ClientConfig gathers the settings a client is built with, see the With*
+// options of the fhir package. Zero values keep the defaults.
+type ClientConfig struct {
+	Version string
+	// HttpClient replaces the default http.Client, its Transport included.
+	HttpClient *http.Client
+	Timeout    time.Duration
+	// TLSConfig and Proxy are set on the transport, the one of HttpClient
+	// included when it is an *http.Transport.
+	TLSConfig *tls.Config
+	Proxy     func(*http.Request) (*url.URL, error)
+	// ApiKey is the name of the header carrying ApiValue on every request,
+	// alongside the Authenticator if any.
+	ApiKey        string
+	ApiValue      string
+	Authenticator IAuthenticator
+	// EntryLimit is the page size requested through _count.
+	EntryLimit int
+	UserAgent  string
+	// Profile tells how to talk to the server, ESANTE_V2_PROFILE by default.
+	Profile *ServerProfile
+	// DisablePathRewrite keeps the base URL as given instead of appending the
+	// profile BasePathSuffix.
+	DisablePathRewrite bool
+	Middlewares        []Middleware
+	RetryPolicy        RetryPolicy
+	RateLimiter        IRateLimiter
+	// Cache keeps GET responses, served without request for CacheTTL and
+	// revalidated through their ETag / Last-Modified afterwards.
+	Cache    ICache
+	CacheTTL time.Duration
+	// Logger receives the client diagnostics, nil keeps the client silent.
+	Logger *slog.Logger
+}
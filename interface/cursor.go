@@ -0,0 +1,24 @@
+package fhirInterface
+
+import "time"
+
+// Cursor marks a position in the pages of a search, so a crawl can resume
+// there after a restart with LoadPage().FromCursor or WithCursor. It is meant
+// to be saved as JSON. Resuming works as long as the server still honours the
+// next link, servers expire their searches after a while.
+type Cursor struct {
+	// NextLink is the link of the page following the cursor, "" once the
+	// search is over.
+	NextLink string `json:"nextLink"`
+	// Page is the number of the page the cursor was taken on, the first being 1.
+	// 0 means unknown, e.g. after jumping to the last page.
+	Page int `json:"page"`
+	// Query identifies the search, e.g. /Organization?address-postalcode=974.
+	Query     string    `json:"query"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Done tells whether the cursor is past the last page.
+func (c Cursor) Done() bool {
+	return c.NextLink == ""
+}
@@ -0,0 +1,217 @@
+package fhirInterface
+
+import "time"
+
+// The comparison prefixes of the ordered parameters, dates and quantities.
+const (
+	PREFIX_EQ = "eq"
+	PREFIX_NE = "ne"
+	PREFIX_GT = "gt"
+	PREFIX_LT = "lt"
+	PREFIX_GE = "ge"
+	PREFIX_LE = "le"
+	PREFIX_SA = "sa" // starts after
+	PREFIX_EB = "eb" // ends before
+	PREFIX_AP = "ap" // approximately
+)
+
+// DatePrecision is the precision a date is sent with. FHIR compares the whole
+// period the date covers: 2026-01 stands for the month of January 2026.
+type DatePrecision int
+
+const (
+	PRECISION_YEAR DatePrecision = iota + 1
+	PRECISION_MONTH
+	PRECISION_DAY
+	PRECISION_SECOND
+)
+
+// FormatDate formats t as a FHIR date or dateTime of the given precision. The
+// seconds are sent with the time zone of t.
+func FormatDate(t time.Time, precision DatePrecision) string {
+	switch precision {
+	case PRECISION_YEAR:
+		return t.Format("2006")
+	case PRECISION_MONTH:
+		return t.Format("2006-01")
+	case PRECISION_DAY:
+		return t.Format("2006-01-02")
+	}
+	return t.Format(time.RFC3339)
+}
+
+func compare(name string, prefix string, value string) UrlParameters {
+	return UrlParameters{
+		Search: []SearchParameter{
+			{
+				Name:   name,
+				Prefix: prefix,
+				Values: []string{value},
+			},
+		},
+	}
+}
+
+// FhirDate searches a date parameter, e.g. birthdate, sent at the day
+// precision unless Precision says otherwise:
+//
+//	FhirDate{Name: "birthdate", Precision: PRECISION_YEAR}.Ge(t) // birthdate=ge1980
+type FhirDate struct {
+	Name      string
+	Precision DatePrecision
+}
+
+func (f FhirDate) compare(prefix string, t time.Time) UrlParameters {
+	precision := f.Precision
+	if precision == 0 {
+		precision = PRECISION_DAY
+	}
+	return compare(f.Name, prefix, FormatDate(t, precision))
+}
+
+func (f FhirDate) Eq(t time.Time) UrlParameters {
+	return f.compare(PREFIX_EQ, t)
+}
+
+func (f FhirDate) Ne(t time.Time) UrlParameters {
+	return f.compare(PREFIX_NE, t)
+}
+
+func (f FhirDate) Gt(t time.Time) UrlParameters {
+	return f.compare(PREFIX_GT, t)
+}
+
+func (f FhirDate) Lt(t time.Time) UrlParameters {
+	return f.compare(PREFIX_LT, t)
+}
+
+func (f FhirDate) Ge(t time.Time) UrlParameters {
+	return f.compare(PREFIX_GE, t)
+}
+
+func (f FhirDate) Le(t time.Time) UrlParameters {
+	return f.compare(PREFIX_LE, t)
+}
+
+// Sa matches the periods starting after the one of t.
+func (f FhirDate) Sa(t time.Time) UrlParameters {
+	return f.compare(PREFIX_SA, t)
+}
+
+// Eb matches the periods ending before the one of t.
+func (f FhirDate) Eb(t time.Time) UrlParameters {
+	return f.compare(PREFIX_EB, t)
+}
+
+// Ap matches the dates close to t, the server deciding how close.
+func (f FhirDate) Ap(t time.Time) UrlParameters {
+	return f.compare(PREFIX_AP, t)
+}
+
+// FhirDateTime searches a dateTime or instant parameter, sent to the second
+// unless Precision says otherwise.
+type FhirDateTime struct {
+	Name      string
+	Precision DatePrecision
+}
+
+func (f FhirDateTime) date() FhirDate {
+	precision := f.Precision
+	if precision == 0 {
+		precision = PRECISION_SECOND
+	}
+	return FhirDate{
+		Name:      f.Name,
+		Precision: precision,
+	}
+}
+
+func (f FhirDateTime) Eq(t time.Time) UrlParameters {
+	return f.date().Eq(t)
+}
+
+func (f FhirDateTime) Ne(t time.Time) UrlParameters {
+	return f.date().Ne(t)
+}
+
+func (f FhirDateTime) Gt(t time.Time) UrlParameters {
+	return f.date().Gt(t)
+}
+
+func (f FhirDateTime) Lt(t time.Time) UrlParameters {
+	return f.date().Lt(t)
+}
+
+func (f FhirDateTime) Ge(t time.Time) UrlParameters {
+	return f.date().Ge(t)
+}
+
+func (f FhirDateTime) Le(t time.Time) UrlParameters {
+	return f.date().Le(t)
+}
+
+func (f FhirDateTime) Sa(t time.Time) UrlParameters {
+	return f.date().Sa(t)
+}
+
+func (f FhirDateTime) Eb(t time.Time) UrlParameters {
+	return f.date().Eb(t)
+}
+
+func (f FhirDateTime) Ap(t time.Time) UrlParameters {
+	return f.date().Ap(t)
+}
+
+var lastUpdated = FhirDateTime{Name: "_lastUpdated"}
+
+// FhirLastUpdated searches on the last update of the resources, common to
+// every resource type, e.g. to only fetch the changes since the previous sync:
+//
+//	Where(models_r4.Practitioner{}.LastUpdated.Gt(lastSync))
+type FhirLastUpdated struct {
+	Value time.Time
+}
+
+// WithPrecision sends the dates with precision instead of to the second.
+func (f FhirLastUpdated) WithPrecision(precision DatePrecision) FhirDateTime {
+	return FhirDateTime{
+		Name:      lastUpdated.Name,
+		Precision: precision,
+	}
+}
+
+func (f FhirLastUpdated) Eq(t time.Time) UrlParameters {
+	return lastUpdated.Eq(t)
+}
+
+func (f FhirLastUpdated) Ne(t time.Time) UrlParameters {
+	return lastUpdated.Ne(t)
+}
+
+func (f FhirLastUpdated) Gt(t time.Time) UrlParameters {
+	return lastUpdated.Gt(t)
+}
+
+func (f FhirLastUpdated) Lt(t time.Time) UrlParameters {
+	return lastUpdated.Lt(t)
+}
+
+func (f FhirLastUpdated) Ge(t time.Time) UrlParameters {
+	return lastUpdated.Ge(t)
+}
+
+func (f FhirLastUpdated) Le(t time.Time) UrlParameters {
+	return lastUpdated.Le(t)
+}
+
+func (f FhirLastUpdated) Sa(t time.Time) UrlParameters {
+	return lastUpdated.Sa(t)
+}
+
+func (f FhirLastUpdated) Eb(t time.Time) UrlParameters {
+	return lastUpdated.Eb(t)
+}
+
+func (f FhirLastUpdated) Ap(t time.Time) UrlParameters {
+	return lastUpdated.Ap(t)
+}
@@ -0,0 +1,95 @@
+package fhirInterface
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestFormatDate(t *testing.T) {
+	utc := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	reunion := time.Date(2026, 1, 1, 12, 0, 0, 0, time.FixedZone("RET", 4*3600))
+	// Still 2025 in New York when it is 2026 in UTC
+	newYork := time.Date(2025, 12, 31, 23, 30, 0, 0, time.FixedZone("EST", -5*3600))
+	tests := []struct {
+		name      string
+		t         time.Time
+		precision DatePrecision
+		want      string
+	}{
+		{"year", utc, PRECISION_YEAR, "2026"},
+		{"month", utc, PRECISION_MONTH, "2026-01"},
+		{"day", utc, PRECISION_DAY, "2026-01-01"},
+		{"second in UTC", utc, PRECISION_SECOND, "2026-01-01T08:00:00Z"},
+		{"second with an offset", reunion, PRECISION_SECOND, "2026-01-01T12:00:00+04:00"},
+		{"unset precision", utc, 0, "2026-01-01T08:00:00Z"},
+		{"sub-second dropped", utc.Add(250 * time.Millisecond), PRECISION_SECOND, "2026-01-01T08:00:00Z"},
+		{"year in the local zone", newYork, PRECISION_YEAR, "2025"},
+		{"day in the local zone", newYork, PRECISION_DAY, "2025-12-31"},
+		{"second in the local zone", newYork, PRECISION_SECOND, "2025-12-31T23:30:00-05:00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatDate(tt.t, tt.precision); got != tt.want {
+				t.Errorf("FormatDate = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDateComparisons(t *testing.T) {
+	instant := time.Date(2026, 1, 1, 8, 0, 0, 0, time.UTC)
+	birthdate := FhirDate{Name: "birthdate"}
+	period := FhirDateTime{Name: "period"}
+	updated := FhirLastUpdated{}
+	tests := []struct {
+		name   string
+		params UrlParameters
+		want   url.Values
+	}{
+		{"date eq", birthdate.Eq(instant), url.Values{"birthdate": {"eq2026-01-01"}}},
+		{"date ne", birthdate.Ne(instant), url.Values{"birthdate": {"ne2026-01-01"}}},
+		{"date gt", birthdate.Gt(instant), url.Values{"birthdate": {"gt2026-01-01"}}},
+		{"date lt", birthdate.Lt(instant), url.Values{"birthdate": {"lt2026-01-01"}}},
+		{"date ge", birthdate.Ge(instant), url.Values{"birthdate": {"ge2026-01-01"}}},
+		{"date le", birthdate.Le(instant), url.Values{"birthdate": {"le2026-01-01"}}},
+		{"date sa", birthdate.Sa(instant), url.Values{"birthdate": {"sa2026-01-01"}}},
+		{"date eb", birthdate.Eb(instant), url.Values{"birthdate": {"eb2026-01-01"}}},
+		{"date ap", birthdate.Ap(instant), url.Values{"birthdate": {"ap2026-01-01"}}},
+		{"date at the year", FhirDate{Name: "birthdate", Precision: PRECISION_YEAR}.Ge(instant), url.Values{"birthdate": {"ge2026"}}},
+		{"dateTime eq", period.Eq(instant), url.Values{"period": {"eq2026-01-01T08:00:00Z"}}},
+		{"dateTime ne", period.Ne(instant), url.Values{"period": {"ne2026-01-01T08:00:00Z"}}},
+		{"dateTime gt", period.Gt(instant), url.Values{"period": {"gt2026-01-01T08:00:00Z"}}},
+		{"dateTime lt", period.Lt(instant), url.Values{"period": {"lt2026-01-01T08:00:00Z"}}},
+		{"dateTime ge", period.Ge(instant), url.Values{"period": {"ge2026-01-01T08:00:00Z"}}},
+		{"dateTime le", period.Le(instant), url.Values{"period": {"le2026-01-01T08:00:00Z"}}},
+		{"dateTime sa", period.Sa(instant), url.Values{"period": {"sa2026-01-01T08:00:00Z"}}},
+		{"dateTime eb", period.Eb(instant), url.Values{"period": {"eb2026-01-01T08:00:00Z"}}},
+		{"dateTime ap", period.Ap(instant), url.Values{"period": {"ap2026-01-01T08:00:00Z"}}},
+		{"dateTime at the month", FhirDateTime{Name: "period", Precision: PRECISION_MONTH}.Lt(instant), url.Values{"period": {"lt2026-01"}}},
+		{"lastUpdated eq", updated.Eq(instant), url.Values{"_lastUpdated": {"eq2026-01-01T08:00:00Z"}}},
+		{"lastUpdated ne", updated.Ne(instant), url.Values{"_lastUpdated": {"ne2026-01-01T08:00:00Z"}}},
+		{"lastUpdated gt", updated.Gt(instant), url.Values{"_lastUpdated": {"gt2026-01-01T08:00:00Z"}}},
+		{"lastUpdated lt", updated.Lt(instant), url.Values{"_lastUpdated": {"lt2026-01-01T08:00:00Z"}}},
+		{"lastUpdated ge", updated.Ge(instant), url.Values{"_lastUpdated": {"ge2026-01-01T08:00:00Z"}}},
+		{"lastUpdated le", updated.Le(instant), url.Values{"_lastUpdated": {"le2026-01-01T08:00:00Z"}}},
+		{"lastUpdated sa", updated.Sa(instant), url.Values{"_lastUpdated": {"sa2026-01-01T08:00:00Z"}}},
+		{"lastUpdated eb", updated.Eb(instant), url.Values{"_lastUpdated": {"eb2026-01-01T08:00:00Z"}}},
+		{"lastUpdated ap", updated.Ap(instant), url.Values{"_lastUpdated": {"ap2026-01-01T08:00:00Z"}}},
+		{"lastUpdated with an offset", updated.Gt(instant.In(time.FixedZone("RET", 4*3600))), url.Values{"_lastUpdated": {"gt2026-01-01T12:00:00+04:00"}}},
+		{"lastUpdated at the day", updated.WithPrecision(PRECISION_DAY).Ge(instant), url.Values{"_lastUpdated": {"ge2026-01-01"}}},
+		{
+			name:   "lastUpdated range",
+			params: updated.Ge(instant).Intersection(updated.Lt(instant.AddDate(0, 1, 0))),
+			want:   url.Values{"_lastUpdated": {"ge2026-01-01T08:00:00Z", "lt2026-02-01T08:00:00Z"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.BuildUrlValues(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildUrlValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+package fhirInterface
+
+import "strconv"
+
+// FhirQuantity searches a quantity parameter, in the unit identified by
+// System and Code when Code is set:
+//
+//	FhirQuantity{Name: "value-quantity", System: "http://unitsofmeasure.org", Code: "mg"}.Lt(5.4)
+//	// value-quantity=lt5.4|http://unitsofmeasure.org|mg
+type FhirQuantity struct {
+	Name   string
+	System string
+	Code   string
+}
+
+func (f FhirQuantity) compare(prefix string, value float64) UrlParameters {
+	v := strconv.FormatFloat(value, 'f', -1, 64)
+	if f.Code != "" {
+		v += "|" + EscapeSearchValue(f.System) + "|" + EscapeSearchValue(f.Code)
+	}
+	params := compare(f.Name, prefix, v)
+	params.Search[0].Escaped = true
+	return params
+}
+
+func (f FhirQuantity) Eq(value float64) UrlParameters {
+	return f.compare(PREFIX_EQ, value)
+}
+
+func (f FhirQuantity) Ne(value float64) UrlParameters {
+	return f.compare(PREFIX_NE, value)
+}
+
+func (f FhirQuantity) Gt(value float64) UrlParameters {
+	return f.compare(PREFIX_GT, value)
+}
+
+func (f FhirQuantity) Lt(value float64) UrlParameters {
+	return f.compare(PREFIX_LT, value)
+}
+
+func (f FhirQuantity) Ge(value float64) UrlParameters {
+	return f.compare(PREFIX_GE, value)
+}
+
+func (f FhirQuantity) Le(value float64) UrlParameters {
+	return f.compare(PREFIX_LE, value)
+}
+
+func (f FhirQuantity) Sa(value float64) UrlParameters {
+	return f.compare(PREFIX_SA, value)
+}
+
+func (f FhirQuantity) Eb(value float64) UrlParameters {
+	return f.compare(PREFIX_EB, value)
+}
+
+// Ap matches the quantities within about 10% of value.
+func (f FhirQuantity) Ap(value float64) UrlParameters {
+	return f.compare(PREFIX_AP, value)
+}
@@ -0,0 +1,41 @@
+package fhirInterface
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestQuantityComparisons(t *testing.T) {
+	dose := FhirQuantity{Name: "value-quantity", System: "http://unitsofmeasure.org", Code: "mg"}
+	tests := []struct {
+		name   string
+		params UrlParameters
+		want   url.Values
+	}{
+		{"eq", dose.Eq(5.4), url.Values{"value-quantity": {"eq5.4|http://unitsofmeasure.org|mg"}}},
+		{"ne", dose.Ne(5.4), url.Values{"value-quantity": {"ne5.4|http://unitsofmeasure.org|mg"}}},
+		{"gt", dose.Gt(5.4), url.Values{"value-quantity": {"gt5.4|http://unitsofmeasure.org|mg"}}},
+		{"lt", dose.Lt(5.4), url.Values{"value-quantity": {"lt5.4|http://unitsofmeasure.org|mg"}}},
+		{"ge", dose.Ge(5.4), url.Values{"value-quantity": {"ge5.4|http://unitsofmeasure.org|mg"}}},
+		{"le", dose.Le(5.4), url.Values{"value-quantity": {"le5.4|http://unitsofmeasure.org|mg"}}},
+		{"sa", dose.Sa(5.4), url.Values{"value-quantity": {"sa5.4|http://unitsofmeasure.org|mg"}}},
+		{"eb", dose.Eb(5.4), url.Values{"value-quantity": {"eb5.4|http://unitsofmeasure.org|mg"}}},
+		{"ap", dose.Ap(5.4), url.Values{"value-quantity": {"ap5.4|http://unitsofmeasure.org|mg"}}},
+		{"without unit", FhirQuantity{Name: "value-quantity"}.Gt(100), url.Values{"value-quantity": {"gt100"}}},
+		{"code without system", FhirQuantity{Name: "value-quantity", Code: "mg"}.Le(0.25), url.Values{"value-quantity": {"le0.25||mg"}}},
+		{"escaped code", FhirQuantity{Name: "value-quantity", System: "urn:units", Code: "mg|kg"}.Eq(1e-3), url.Values{"value-quantity": {`eq0.001|urn:units|mg\|kg`}}},
+		{
+			name:   "quantity range",
+			params: dose.Ge(1).Intersection(dose.Lt(5.4)),
+			want:   url.Values{"value-quantity": {"ge1|http://unitsofmeasure.org|mg", "lt5.4|http://unitsofmeasure.org|mg"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.BuildUrlValues(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildUrlValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package fhirInterface
+
+import "fmt"
+
+// HttpError is returned when the FHIR server answers with a non-2xx status.
+// It keeps the raw response body so callers can inspect what the server sent,
+// and the parsed OperationOutcome when the body was one.
+type HttpError struct {
+	Method     string
+	Url        string
+	StatusCode int
+	Status     string
+	Body       []byte
+	Outcome    error
+}
+
+func (e *HttpError) Error() string {
+	if e.Outcome != nil {
+		return fmt.Sprintf("fhir: %s %s: %s: %v", e.Method, e.Url, e.Status, e.Outcome)
+	}
+	return fmt.Sprintf("fhir: %s %s: %s", e.Method, e.Url, e.Status)
+}
+
+// Unwrap exposes the OperationOutcome so errors.As can reach it.
+func (e *HttpError) Unwrap() error {
+	return e.Outcome
+}
@@ -0,0 +1,58 @@
+package fhirInterface
+
+// IterateOptions tunes the iteration of IRequest.All, Pages and Stream. Zero
+// means no limit.
+type IterateOptions struct {
+	// MaxResults stops the iteration after that many entries.
+	MaxResults int
+	// MaxPages stops the iteration after that many pages, the first included.
+	MaxPages int
+	// Checkpoint is called once the entries of a page are all yielded, with
+	// the cursor to resume after it. An error ends the iteration.
+	Checkpoint func(Cursor) error
+	// Cursor resumes the iteration after the page it was taken on, instead of
+	// running the search from its first page.
+	Cursor *Cursor
+	// Prefetch fetches up to that many pages in the background while the
+	// current one is processed. 0 fetches each page on demand.
+	Prefetch int
+}
+
+type IterateOption func(*IterateOptions)
+
+func WithMaxResults(n int) IterateOption {
+	return func(o *IterateOptions) {
+		o.MaxResults = n
+	}
+}
+
+func WithMaxPages(n int) IterateOption {
+	return func(o *IterateOptions) {
+		o.MaxPages = n
+	}
+}
+
+func WithCheckpoint(checkpoint func(Cursor) error) IterateOption {
+	return func(o *IterateOptions) {
+		o.Checkpoint = checkpoint
+	}
+}
+
+func WithCursor(cursor Cursor) IterateOption {
+	return func(o *IterateOptions) {
+		o.Cursor = &cursor
+	}
+}
+
+func WithPrefetch(pages int) IterateOption {
+	return func(o *IterateOptions) {
+		o.Prefetch = pages
+	}
+}
+
+// EntryResult is an item of IRequest.Stream: an entry, or the error that
+// ended the iteration.
+type EntryResult struct {
+	Entry IEntry
+	Err   error
+}
@@ -0,0 +1,15 @@
+package fhirInterface
+
+import "net/http"
+
+// Middleware wraps the transport of the client, it sees every attempt of
+// every request once authenticated, retries included.
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc turns a function into an http.RoundTripper, to write
+// middlewares inline.
+type RoundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (f RoundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
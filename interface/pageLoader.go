@@ -0,0 +1,108 @@
+package fhirInterface
+
+import (
+	"context"
+	"iter"
+	"log/slog"
+)
+
+// PageLoader walks the pages of a search result, see IClient.LoadPage. Except
+// Next, each function fetches the page right away, giving up as soon as ctx
+// is done. Without a next link, the request Next returns fails when executed.
+type PageLoader struct {
+	Next        func(IResourceResult) IRequest
+	NextContext func(context.Context, IResourceResult) (IResourceResult, error)
+	Previous    func(context.Context, IResourceResult) (IResourceResult, error)
+	First       func(context.Context, IResourceResult) (IResourceResult, error)
+	Last        func(context.Context, IResourceResult) (IResourceResult, error)
+	// Self fetches the current page again, e.g. to refresh it.
+	Self func(context.Context, IResourceResult) (IResourceResult, error)
+	// FromCursor fetches the page following a saved Cursor.
+	FromCursor func(context.Context, Cursor) (IResourceResult, error)
+}
+
+// NewPageLoader returns the PageLoader following the links of the results,
+// the way their client rebuilds the requests. resume turns a Cursor back into
+// the result it was taken from, see FromCursor.
+func NewPageLoader(logger *slog.Logger, resume func(Cursor) IResourceResult) PageLoader {
+	load := func(relation string) func(context.Context, IResourceResult) (IResourceResult, error) {
+		return func(ctx context.Context, res IResourceResult) (IResourceResult, error) {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			logger.Debug("LoadPage", "relation", relation, "link", res.GetLink(relation))
+			req, err := res.MakeRequestPage(relation)
+			if err != nil {
+				return nil, err
+			}
+			return req.ExecuteBundleContext(ctx)
+		}
+	}
+	return PageLoader{
+		Next: func(res IResourceResult) IRequest {
+			logger.Debug("LoadNextPage", "next", res.GetNextLink())
+			req, err := res.MakeRequestNextPage()
+			if err != nil {
+				return errorRequest{err}
+			}
+			return req
+		},
+		NextContext: load(LINK_NEXT),
+		Previous:    load(LINK_PREVIOUS),
+		First:       load(LINK_FIRST),
+		Last:        load(LINK_LAST),
+		Self:        load(LINK_SELF),
+		FromCursor: func(ctx context.Context, cursor Cursor) (IResourceResult, error) {
+			return load(LINK_NEXT)(ctx, resume(cursor))
+		},
+	}
+}
+
+// errorRequest is a request that can't be built, failing with err whichever
+// way it is executed.
+type errorRequest struct {
+	err error
+}
+
+func (r errorRequest) Execute() interface{} {
+	return nil
+}
+
+func (r errorRequest) ExecuteContext(ctx context.Context) interface{} {
+	return nil
+}
+
+func (r errorRequest) ExecuteBundle() (IResourceResult, error) {
+	return nil, r.err
+}
+
+func (r errorRequest) ExecuteBundleContext(ctx context.Context) (IResourceResult, error) {
+	return nil, r.err
+}
+
+func (r errorRequest) ExecuteRaw() ([]byte, error) {
+	return nil, r.err
+}
+
+func (r errorRequest) ExecuteRawContext(ctx context.Context) ([]byte, error) {
+	return nil, r.err
+}
+
+func (r errorRequest) All(ctx context.Context, opts ...IterateOption) iter.Seq2[IEntry, error] {
+	return func(yield func(IEntry, error) bool) {
+		yield(nil, r.err)
+	}
+}
+
+func (r errorRequest) Pages(ctx context.Context, opts ...IterateOption) iter.Seq2[IResourceResult, error] {
+	return func(yield func(IResourceResult, error) bool) {
+		yield(nil, r.err)
+	}
+}
+
+func (r errorRequest) Stream(ctx context.Context, opts ...IterateOption) <-chan EntryResult {
+	results := make(chan EntryResult, 1)
+	results <- EntryResult{Err: r.err}
+	close(results)
+	return results
+}
@@ -0,0 +1,88 @@
+package fhirInterface
+
+import (
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	DEFAULT_RETRY_MAX_ATTEMPTS    = 4
+	DEFAULT_RETRY_INITIAL_BACKOFF = 500 * time.Millisecond
+	DEFAULT_RETRY_MAX_BACKOFF     = 30 * time.Second
+)
+
+// RetryPolicy describes how failed calls are retried. The zero value disables
+// retries.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, the first one included.
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	// MaxBackoff caps the backoff. A Retry-After asking for a longer wait
+	// stops the retries, the response being returned as is.
+	MaxBackoff time.Duration
+	// Multiplier grows the backoff between two attempts, 2 when unset.
+	Multiplier float64
+	// Jitter randomizes each backoff by up to this fraction (0.2 = ±20%).
+	Jitter float64
+	// RetryableStatus lists the HTTP status codes worth another attempt.
+	RetryableStatus []int
+	// RetryNetworkErrors retries when no response was received at all
+	// (connection reset, timeout, DNS failure...).
+	RetryNetworkErrors bool
+	// RetryNonIdempotent allows retrying methods other than GET and HEAD.
+	RetryNonIdempotent bool
+}
+
+// DefaultRetryPolicy retries GETs on 429, 502, 503, 504 and network errors,
+// up to 4 attempts with an exponential backoff from 500ms to 30s.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    DEFAULT_RETRY_MAX_ATTEMPTS,
+		InitialBackoff: DEFAULT_RETRY_INITIAL_BACKOFF,
+		MaxBackoff:     DEFAULT_RETRY_MAX_BACKOFF,
+		Multiplier:     2,
+		Jitter:         0.2,
+		RetryableStatus: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+		RetryNetworkErrors: true,
+	}
+}
+
+// AllowsMethod reports whether requests of this method may be retried.
+func (p RetryPolicy) AllowsMethod(method string) bool {
+	if p.RetryNonIdempotent {
+		return true
+	}
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+func (p RetryPolicy) IsRetryableStatus(status int) bool {
+	for _, s := range p.RetryableStatus {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Backoff returns the delay to wait after the given failed attempt (1-based).
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier <= 0 {
+		multiplier = 2
+	}
+	backoff := float64(p.InitialBackoff) * math.Pow(multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		backoff += backoff * p.Jitter * (2*rand.Float64() - 1)
+	}
+	if p.MaxBackoff > 0 && backoff > float64(p.MaxBackoff) {
+		backoff = float64(p.MaxBackoff)
+	}
+	return time.Duration(backoff)
+}
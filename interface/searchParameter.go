@@ -0,0 +1,68 @@
+package fhirInterface
+
+import "strings"
+
+// SearchParameter is one clause of a search, rendered as
+// Name[:Modifier]=[Prefix]Values[0],[Prefix]Values[1]... The values are
+// matched with OR, the clauses of a query with AND.
+type SearchParameter struct {
+	Name string
+	// Modifier refines the matching, e.g. exact, contains, missing or not.
+	Modifier string
+	// Prefix compares ordered values, e.g. ge, lt or eq for dates.
+	Prefix string
+	// Values are escaped when rendered, so a comma or a | they contain is
+	// searched as is rather than read as a separator.
+	Values []string
+	// Escaped tells Values are already escaped, their separators meant, e.g.
+	// the | of system|code.
+	Escaped bool
+}
+
+// Key is the query parameter name, modifier included.
+func (p SearchParameter) Key() string {
+	if p.Modifier == "" {
+		return p.Name
+	}
+	return p.Name + ":" + p.Modifier
+}
+
+// Value is the query parameter value, the OR values joined with commas.
+func (p SearchParameter) Value() string {
+	return strings.Join(p.formatted(), ",")
+}
+
+// formatted returns the values escaped and prefixed.
+func (p SearchParameter) formatted() []string {
+	values := make([]string, len(p.Values))
+	for i, v := range p.Values {
+		if !p.Escaped {
+			v = EscapeSearchValue(v)
+		}
+		values[i] = p.Prefix + v
+	}
+	return values
+}
+
+var searchValueEscaper = strings.NewReplacer(`\`, `\\`, ",", `\,`, "|", `\|`, "$", `\$`)
+
+// EscapeSearchValue escapes the FHIR separators of a search value: \, the
+// comma, | and $.
+func EscapeSearchValue(v string) string {
+	return searchValueEscaper.Replace(v)
+}
+
+// Param searches on any parameter, including those without a typed helper,
+// matching any of values:
+//
+//	Where(fhirInterface.Param("family", "Payet", "Hoarau"))
+func Param(name string, values ...string) UrlParameters {
+	return UrlParameters{
+		Search: []SearchParameter{
+			{
+				Name:   name,
+				Values: values,
+			},
+		},
+	}
+}
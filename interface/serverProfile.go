@@ -0,0 +1,68 @@
+package fhirInterface
+
+import (
+	"errors"
+	"strings"
+)
+
+type PaginationStrategy string
+
+const (
+	// PAGINATION_ESANTE rebuilds next pages as /_page?id=<token>, as the esante annuaire v2 expects.
+	PAGINATION_ESANTE PaginationStrategy = "esante"
+	// PAGINATION_HAPI rebuilds next pages as /?_getpages=<id>&_pageId=..., as HAPI FHIR expects.
+	PAGINATION_HAPI PaginationStrategy = "hapi"
+	// PAGINATION_NEXT_LINK follows the next link verbatim, as the FHIR specification expects.
+	PAGINATION_NEXT_LINK PaginationStrategy = "next-link"
+)
+
+// ServerProfile gathers what differs from one FHIR server to another, so the
+// same client works against each of them.
+type ServerProfile struct {
+	Name string
+	// BasePathSuffix is appended to the base URL when missing, e.g. /v2.
+	BasePathSuffix string
+	Pagination     PaginationStrategy
+	// DefaultHeaders are sent on every request.
+	DefaultHeaders map[string]string
+	// SupportedParameters lists the search parameters the server understands.
+	// A request with another one fails with ErrUnsupportedParameter rather
+	// than matching more than asked, _count only being dropped. Nil means
+	// every parameter.
+	SupportedParameters []string
+}
+
+// ErrUnsupportedParameter is returned for a request on a parameter missing
+// from the SupportedParameters of the profile.
+var ErrUnsupportedParameter = errors.New("fhir: search parameter not supported by the server")
+
+var (
+	ESANTE_V2_PROFILE = ServerProfile{
+		Name:           "esante-annuaire-v2",
+		BasePathSuffix: "/v2",
+		Pagination:     PAGINATION_ESANTE,
+	}
+	HAPI_PROFILE = ServerProfile{
+		Name:       "hapi",
+		Pagination: PAGINATION_HAPI,
+	}
+	GENERIC_PROFILE = ServerProfile{
+		Name:       "generic",
+		Pagination: PAGINATION_NEXT_LINK,
+	}
+)
+
+// SupportsParameter tells whether the server understands the parameter,
+// given with or without its modifier.
+func (p ServerProfile) SupportsParameter(name string) bool {
+	if p.SupportedParameters == nil {
+		return true
+	}
+	name, _, _ = strings.Cut(name, ":")
+	for _, supported := range p.SupportedParameters {
+		if supported == name {
+			return true
+		}
+	}
+	return false
+}
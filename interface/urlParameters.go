@@ -1,47 +1,44 @@
 package fhirInterface
 
-import "net/url"
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
 
+// UrlParameters holds the query of a search. Search lists its clauses, each
+// sent as its own parameter and matched with AND, as FHIR expects; the other
+// fields drive the pagination and the result.
 type UrlParameters struct {
-	Id                string // pagination token (esante v2 uses `id`)
-	SearchId          string // resource id search uses `_id`
-	Name              string
-	Address           string
-	Role              string
-	QualificationCode string
-	Active            bool
-	GetPages          string
-	PageId            string
-	BundleType        string
-	Count             string
-	RevInclude        string
+	Search     []SearchParameter
+	Id         string // pagination token (esante v2 uses `id`)
+	GetPages   string
+	PageId     string
+	BundleType string
+	Count      string
+	RevInclude string
+	// Err records a query that can't be sent as built, e.g. an Or across
+	// parameters. Executing it fails with Err.
+	Err error
 }
 
+// ErrOrAcrossParameters is returned for a query ORing different parameters,
+// or the same parameter with different modifiers, which FHIR can't express.
+var ErrOrAcrossParameters = errors.New("fhir: Or only joins values of the same parameter and modifier")
+
 func (u UrlParameters) BuildUrlValues() url.Values {
 	values := url.Values{}
-	if u.Name != "" {
-		values.Add("name", u.Name)
-	}
-	if u.Address != "" {
-		values.Add("address-postalcode", u.Address)
-	}
-	if u.Role != "" {
-		values.Add("role", u.Role)
-	}
-	if u.QualificationCode != "" {
-		values.Add("qualification-code", u.QualificationCode)
+	for _, p := range u.Search {
+		if len(p.Values) > 0 {
+			values.Add(p.Key(), p.Value())
+		}
 	}
 	// Support v2 pagination via /_page?id=...
 	if u.Id != "" {
 		values.Add("id", u.Id)
 	}
-	// Resource id search uses `_id`
-	if u.SearchId != "" {
-		values.Add("_id", u.SearchId)
-	}
-	if u.Active {
-		values.Add("active", "true")
-	}
 	if u.GetPages != "" {
 		values.Add("_getpages", u.GetPages)
 	}
@@ -60,99 +57,366 @@ func (u UrlParameters) BuildUrlValues() url.Values {
 	return values
 }
 
+// Intersection ANDs u_cur to u: its clauses are added as repeated parameters.
 func (u UrlParameters) Intersection(u_cur UrlParameters) UrlParameters {
-	if u_cur.Active {
-		u.Active = u_cur.Active
+	// Copy, UrlParameters are values and must not share their slices
+	search := append([]SearchParameter(nil), u.Search...)
+	for _, p := range u_cur.Search {
+		if !containsClause(search, p) {
+			search = append(search, p)
+		}
+	}
+	u.Search = search
+	if u_cur.RevInclude != "" {
+		u.RevInclude = u_cur.RevInclude
 	}
-	if u_cur.QualificationCode != "" {
-		u.QualificationCode = u_cur.QualificationCode
+	if u.Err == nil {
+		u.Err = u_cur.Err
 	}
 	return u
 }
 
+// Union ORs u_cur to u: its values are joined with commas to the last clause
+// of the same parameter and modifier. FHIR can't OR different parameters, a
+// parameter or modifier u doesn't have yet sets Err to ErrOrAcrossParameters,
+// unless u is still empty.
 func (u UrlParameters) Union(u_cur UrlParameters) UrlParameters {
-	if u_cur.Name != "" {
-		u.Name = u.Name + "," + u_cur.Name
+	if u.Err == nil {
+		u.Err = u_cur.Err
 	}
-	if u_cur.Address != "" {
-		u.Address = u.Address + "," + u_cur.Address
+	search := append([]SearchParameter(nil), u.Search...)
+	for _, p := range u_cur.Search {
+		last := -1
+		for i, existing := range search {
+			if existing.Key() == p.Key() {
+				last = i
+			}
+		}
+		if last < 0 {
+			if len(u.Search) > 0 && u.Err == nil {
+				u.Err = fmt.Errorf("%w: %s", ErrOrAcrossParameters, p.Key())
+			}
+			search = append(search, p)
+			continue
+		}
+		joined := search[last]
+		if joined.Prefix != p.Prefix || joined.Escaped != p.Escaped {
+			// Each value keeps its own prefix and escaping
+			joined = SearchParameter{
+				Name:     joined.Name,
+				Modifier: joined.Modifier,
+				Values:   joined.formatted(),
+				Escaped:  true,
+			}
+			p.Values = p.formatted()
+		}
+		joined.Values = append(append([]string(nil), joined.Values...), p.Values...)
+		search[last] = joined
 	}
-	if u_cur.QualificationCode != "" {
-		u.QualificationCode = u.QualificationCode + "," + u_cur.QualificationCode
+	u.Search = search
+	if u_cur.RevInclude != "" {
+		u.RevInclude = u_cur.RevInclude
 	}
 	return u
 }
 
-type FhirName struct {
-	Value string
+func containsClause(search []SearchParameter, p SearchParameter) bool {
+	for _, existing := range search {
+		if existing.Key() == p.Key() && existing.Value() == p.Value() {
+			return true
+		}
+	}
+	return false
 }
 
-func (f FhirName) Contains() struct {
+// SearchValue completes a typed search criterion with its value:
+//
+//	models_r4.Organization{}.Name.Exact().Value("Cabinet Payet")
+type SearchValue struct {
 	Value func(v string) UrlParameters
-} {
-	return struct {
-		Value func(v string) UrlParameters
-	}{
+}
+
+func searchValue(name string, modifier string) SearchValue {
+	return SearchValue{
 		Value: func(v string) UrlParameters {
 			return UrlParameters{
-				Name: v,
+				Search: []SearchParameter{
+					{
+						Name:     name,
+						Modifier: modifier,
+						Values:   []string{v},
+					},
+				},
 			}
 		},
 	}
 }
 
+// missing searches for the resources without (true) or with (false) a value
+// for the parameter.
+func missing(name string, missing bool) UrlParameters {
+	return searchValue(name, "missing").Value(strconv.FormatBool(missing))
+}
+
+// The string fields match the start of the value, ignoring case and accents,
+// unless Exact or Contains is used.
+
+type FhirName struct {
+	Value string
+}
+
+// StartsWith matches the names starting with the value, the plain FHIR string
+// search.
+func (f FhirName) StartsWith() SearchValue {
+	return searchValue("name", "")
+}
+
+// Exact matches the whole name, case and accents included.
+func (f FhirName) Exact() SearchValue {
+	return searchValue("name", "exact")
+}
+
+// Contains matches the names containing the value anywhere.
+func (f FhirName) Contains() SearchValue {
+	return searchValue("name", "contains")
+}
+
+func (f FhirName) Missing(m bool) UrlParameters {
+	return missing("name", m)
+}
+
 type FhirAddress struct {
 	Value string
 }
 
-func (f FhirAddress) Contains() struct {
-	Value func(v string) UrlParameters
-} {
-	return struct {
-		Value func(v string) UrlParameters
-	}{
+// StartsWith matches the postal codes starting with the value, e.g. 974 for
+// La Réunion.
+func (f FhirAddress) StartsWith() SearchValue {
+	return searchValue("address-postalcode", "")
+}
+
+func (f FhirAddress) Exact() SearchValue {
+	return searchValue("address-postalcode", "exact")
+}
+
+func (f FhirAddress) Contains() SearchValue {
+	return searchValue("address-postalcode", "contains")
+}
+
+func (f FhirAddress) Missing(m bool) UrlParameters {
+	return missing("address-postalcode", m)
+}
+
+// FhirToken searches a token parameter, a code that may belong to a code
+// system such as an identifier or a coded qualification. The typed token
+// fields of the models delegate to it.
+type FhirToken struct {
+	Name string
+}
+
+// Code matches the code whatever its system. A | in code is part of the code.
+func (f FhirToken) Code(code string) UrlParameters {
+	return Param(f.Name, code)
+}
+
+// SystemAndCode matches the code of system only, e.g. an RPPS number:
+//
+//	SystemAndCode("https://rpps.esante.gouv.fr", "10001234567")
+func (f FhirToken) SystemAndCode(system string, code string) UrlParameters {
+	return token(f.Name, "", EscapeSearchValue(system)+"|"+EscapeSearchValue(code))
+}
+
+// SystemOnly matches any code of system.
+func (f FhirToken) SystemOnly(system string) UrlParameters {
+	return token(f.Name, "", EscapeSearchValue(system)+"|")
+}
+
+// Not, In, Above and Below take a value in any of the token forms: code,
+// system|code, |code or system|, the first | separating the system.
+
+// Not matches the resources without the value, including those without any.
+func (f FhirToken) Not() SearchValue {
+	return tokenValue(f.Name, "not")
+}
+
+// Text matches the start of the text or display of the code.
+func (f FhirToken) Text() SearchValue {
+	return searchValue(f.Name, "text")
+}
+
+// In matches the codes of the ValueSet whose URL is the value.
+func (f FhirToken) In() SearchValue {
+	return tokenValue(f.Name, "in")
+}
+
+// Above matches the code and the codes it specializes.
+func (f FhirToken) Above() SearchValue {
+	return tokenValue(f.Name, "above")
+}
+
+// Below matches the code and the codes specializing it.
+func (f FhirToken) Below() SearchValue {
+	return tokenValue(f.Name, "below")
+}
+
+func (f FhirToken) Missing(m bool) UrlParameters {
+	return missing(f.Name, m)
+}
+
+// token is a clause on an already escaped token value.
+func token(name string, modifier string, value string) UrlParameters {
+	return UrlParameters{
+		Search: []SearchParameter{
+			{
+				Name:     name,
+				Modifier: modifier,
+				Values:   []string{value},
+				Escaped:  true,
+			},
+		},
+	}
+}
+
+// tokenValue reads the value in the token forms, the first | separating the
+// system from the code.
+func tokenValue(name string, modifier string) SearchValue {
+	return SearchValue{
 		Value: func(v string) UrlParameters {
-			return UrlParameters{
-				Address: v,
+			system, code, hasSystem := strings.Cut(v, "|")
+			if !hasSystem {
+				return token(name, modifier, EscapeSearchValue(v))
 			}
+			return token(name, modifier, EscapeSearchValue(system)+"|"+EscapeSearchValue(code))
 		},
 	}
 }
 
+var (
+	roleToken              = FhirToken{Name: "role"}
+	qualificationCodeToken = FhirToken{Name: "qualification-code"}
+	identifierToken        = FhirToken{Name: "identifier"}
+	activeToken            = FhirToken{Name: "active"}
+)
+
 type FhirRole struct {
 	Value string
 }
 
-func (f FhirRole) Contains() struct {
-	Value func(v string) UrlParameters
-} {
-	return struct {
-		Value func(v string) UrlParameters
-	}{
-		Value: func(v string) UrlParameters {
-			return UrlParameters{
-				Role: v,
-			}
-		},
-	}
+func (f FhirRole) Code(code string) UrlParameters {
+	return roleToken.Code(code)
+}
+
+func (f FhirRole) SystemAndCode(system string, code string) UrlParameters {
+	return roleToken.SystemAndCode(system, code)
+}
+
+func (f FhirRole) SystemOnly(system string) UrlParameters {
+	return roleToken.SystemOnly(system)
+}
+
+// Deprecated: Contains matches the code exactly, use Code.
+func (f FhirRole) Contains() SearchValue {
+	return tokenValue("role", "")
+}
+
+func (f FhirRole) Not() SearchValue {
+	return roleToken.Not()
+}
+
+func (f FhirRole) Text() SearchValue {
+	return roleToken.Text()
+}
+
+func (f FhirRole) In() SearchValue {
+	return roleToken.In()
+}
+
+func (f FhirRole) Above() SearchValue {
+	return roleToken.Above()
+}
+
+func (f FhirRole) Below() SearchValue {
+	return roleToken.Below()
+}
+
+func (f FhirRole) Missing(m bool) UrlParameters {
+	return roleToken.Missing(m)
 }
 
 type FhirQualificationCode struct {
 	Value string
 }
 
-func (f FhirQualificationCode) Contains() struct {
-	Value func(v string) UrlParameters
-} {
-	return struct {
-		Value func(v string) UrlParameters
-	}{
-		Value: func(v string) UrlParameters {
-			return UrlParameters{
-				QualificationCode: v,
-			}
-		},
-	}
+func (f FhirQualificationCode) Code(code string) UrlParameters {
+	return qualificationCodeToken.Code(code)
+}
+
+func (f FhirQualificationCode) SystemAndCode(system string, code string) UrlParameters {
+	return qualificationCodeToken.SystemAndCode(system, code)
+}
+
+func (f FhirQualificationCode) SystemOnly(system string) UrlParameters {
+	return qualificationCodeToken.SystemOnly(system)
+}
+
+// Deprecated: Contains matches the code exactly, use Code.
+func (f FhirQualificationCode) Contains() SearchValue {
+	return tokenValue("qualification-code", "")
+}
+
+func (f FhirQualificationCode) Not() SearchValue {
+	return qualificationCodeToken.Not()
+}
+
+func (f FhirQualificationCode) Text() SearchValue {
+	return qualificationCodeToken.Text()
+}
+
+func (f FhirQualificationCode) In() SearchValue {
+	return qualificationCodeToken.In()
+}
+
+func (f FhirQualificationCode) Above() SearchValue {
+	return qualificationCodeToken.Above()
+}
+
+func (f FhirQualificationCode) Below() SearchValue {
+	return qualificationCodeToken.Below()
+}
+
+func (f FhirQualificationCode) Missing(m bool) UrlParameters {
+	return qualificationCodeToken.Missing(m)
+}
+
+// FhirIdentifier searches the business identifiers, e.g. the RPPS number of a
+// practitioner or the FINESS number of an organization.
+type FhirIdentifier struct {
+	Value string
+}
+
+func (f FhirIdentifier) Code(code string) UrlParameters {
+	return identifierToken.Code(code)
+}
+
+func (f FhirIdentifier) SystemAndCode(system string, code string) UrlParameters {
+	return identifierToken.SystemAndCode(system, code)
+}
+
+func (f FhirIdentifier) SystemOnly(system string) UrlParameters {
+	return identifierToken.SystemOnly(system)
+}
+
+func (f FhirIdentifier) Not() SearchValue {
+	return identifierToken.Not()
+}
+
+// Text matches the start of the identifier type text.
+func (f FhirIdentifier) Text() SearchValue {
+	return identifierToken.Text()
+}
+
+func (f FhirIdentifier) Missing(m bool) UrlParameters {
+	return identifierToken.Missing(m)
 }
 
 type FhirActive struct {
@@ -160,7 +424,21 @@ type FhirActive struct {
 }
 
 func (f FhirActive) IsActive() UrlParameters {
-	return UrlParameters{
-		Active: true,
-	}
+	return activeToken.Code("true")
+}
+
+// IsInactive matches the resources flagged inactive, not those without the
+// flag.
+func (f FhirActive) IsInactive() UrlParameters {
+	return activeToken.Code("false")
+}
+
+// Not matches the resources whose flag differs from the value, including those
+// without it: Not().Value("true") is every resource not known to be active.
+func (f FhirActive) Not() SearchValue {
+	return activeToken.Not()
+}
+
+func (f FhirActive) Missing(m bool) UrlParameters {
+	return activeToken.Missing(m)
 }
@@ -0,0 +1,221 @@
+package fhirInterface
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func TestBuildUrlValues(t *testing.T) {
+	name := FhirName{}
+	address := FhirAddress{}
+	role := FhirRole{}
+	active := FhirActive{}
+	lastUpdated := func(prefix string, value string) UrlParameters {
+		return UrlParameters{
+			Search: []SearchParameter{
+				{
+					Name:   "_lastUpdated",
+					Prefix: prefix,
+					Values: []string{value},
+				},
+			},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		params  UrlParameters
+		want    url.Values
+		wantErr error
+	}{
+		{
+			name:   "and of different parameters",
+			params: name.StartsWith().Value("cab").Intersection(address.StartsWith().Value("974")),
+			want: url.Values{
+				"name":               {"cab"},
+				"address-postalcode": {"974"},
+			},
+		},
+		{
+			name:   "and repeating the same parameter",
+			params: address.StartsWith().Value("974").Intersection(address.StartsWith().Value("97411")),
+			want: url.Values{
+				"address-postalcode": {"974", "97411"},
+			},
+		},
+		{
+			name:   "or on the same parameter",
+			params: address.StartsWith().Value("974").Union(address.StartsWith().Value("976")),
+			want: url.Values{
+				"address-postalcode": {"974,976"},
+			},
+		},
+		{
+			name:   "or joining the last clause of the parameter",
+			params: address.StartsWith().Value("974").Intersection(address.StartsWith().Value("97411")).Union(address.StartsWith().Value("976")),
+			want: url.Values{
+				"address-postalcode": {"974", "97411,976"},
+			},
+		},
+		{
+			name:   "or with a parameter not present yet",
+			params: name.StartsWith().Value("cab").Union(address.StartsWith().Value("974")),
+			want: url.Values{
+				"name":               {"cab"},
+				"address-postalcode": {"974"},
+			},
+			wantErr: ErrOrAcrossParameters,
+		},
+		{
+			name:   "or across modifiers",
+			params: name.Exact().Value("Payet").Union(name.StartsWith().Value("Hoa")),
+			want: url.Values{
+				"name:exact": {"Payet"},
+				"name":       {"Hoa"},
+			},
+			wantErr: ErrOrAcrossParameters,
+		},
+		{
+			name:   "or across parameters is kept by a later and",
+			params: name.StartsWith().Value("cab").Union(address.StartsWith().Value("974")).Intersection(active.IsActive()),
+			want: url.Values{
+				"name":               {"cab"},
+				"address-postalcode": {"974"},
+				"active":             {"true"},
+			},
+			wantErr: ErrOrAcrossParameters,
+		},
+		{
+			name:   "or across parameters is kept when anded",
+			params: active.IsActive().Intersection(name.StartsWith().Value("cab").Union(address.StartsWith().Value("974"))),
+			want: url.Values{
+				"active":             {"true"},
+				"name":               {"cab"},
+				"address-postalcode": {"974"},
+			},
+			wantErr: ErrOrAcrossParameters,
+		},
+		{
+			name:   "or on an empty query",
+			params: UrlParameters{}.Union(name.StartsWith().Value("cab")),
+			want: url.Values{
+				"name": {"cab"},
+			},
+		},
+		{
+			name:   "or with the same prefix",
+			params: lastUpdated("ge", "2024").Union(lastUpdated("ge", "2025")),
+			want: url.Values{
+				"_lastUpdated": {"ge2024,ge2025"},
+			},
+		},
+		{
+			name:   "or with mixed prefixes",
+			params: lastUpdated("ge", "2025").Union(lastUpdated("lt", "2020")),
+			want: url.Values{
+				"_lastUpdated": {"ge2025,lt2020"},
+			},
+		},
+		{
+			name:   "and with mixed prefixes",
+			params: lastUpdated("ge", "2020").Intersection(lastUpdated("lt", "2025")),
+			want: url.Values{
+				"_lastUpdated": {"ge2020", "lt2025"},
+			},
+		},
+		{
+			name:   "and de-duplicates the same clause",
+			params: active.IsActive().Intersection(role.Code("70")).Intersection(active.IsActive()),
+			want: url.Values{
+				"active": {"true"},
+				"role":   {"70"},
+			},
+		},
+		{
+			name:   "and keeps clauses differing by their values",
+			params: role.Code("70").Intersection(role.Code("70").Union(role.Code("60"))),
+			want: url.Values{
+				"role": {"70", "70,60"},
+			},
+		},
+		{
+			name:   "or of escaped and plain values",
+			params: role.Code("a,b").Union(role.SystemAndCode("http://sys", "70")),
+			want: url.Values{
+				"role": {`a\,b,http://sys|70`},
+			},
+		},
+		{
+			name:   "separators in values are escaped",
+			params: Param("name", "Dupont, Jean", `a|b$c\d`),
+			want: url.Values{
+				"name": {`Dupont\, Jean,a\|b\$c\\d`},
+			},
+		},
+		{
+			name:   "inactive",
+			params: active.IsInactive(),
+			want: url.Values{
+				"active": {"false"},
+			},
+		},
+		{
+			name:   "not active",
+			params: active.Not().Value("true"),
+			want: url.Values{
+				"active:not": {"true"},
+			},
+		},
+		{
+			name:   "active flag missing",
+			params: active.Missing(true),
+			want: url.Values{
+				"active:missing": {"true"},
+			},
+		},
+		{
+			name:   "or of not and missing",
+			params: active.Not().Value("true").Union(active.Missing(true)),
+			want: url.Values{
+				"active:not":     {"true"},
+				"active:missing": {"true"},
+			},
+			wantErr: ErrOrAcrossParameters,
+		},
+		{
+			name: "search parameters come with the paging ones",
+			params: UrlParameters{
+				Search:     Param("_id", "prat-001").Search,
+				Count:      "20",
+				RevInclude: "PractitionerRole:practitioner",
+			},
+			want: url.Values{
+				"_id":         {"prat-001"},
+				"_count":      {"20"},
+				"_revinclude": {"PractitionerRole:practitioner"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.params.BuildUrlValues(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("BuildUrlValues() = %v, want %v", got, tt.want)
+			}
+			if !errors.Is(tt.params.Err, tt.wantErr) {
+				t.Errorf("Err = %v, want %v", tt.params.Err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUnionDoesNotAlias(t *testing.T) {
+	address := FhirAddress{}
+	base := address.StartsWith().Value("974")
+	base.Union(address.StartsWith().Value("976"))
+	base.Intersection(address.StartsWith().Value("976"))
+	if got := base.BuildUrlValues().Encode(); got != "address-postalcode=974" {
+		t.Errorf("base modified by Union or Intersection: %s", got)
+	}
+}
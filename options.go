@@ -0,0 +1,210 @@
+package fhir
+
+import (
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+	clients_r4 "github.com/LGMorgan/go-fhir/versions/r4/clients"
+)
+
+// Option customizes the client built by New.
+type Option func(*fhirInterface.ClientConfig)
+
+// WithVersion selects the FHIR version spoken by the server, R4 by default.
+func WithVersion(version FhirVersion) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Version = string(version)
+	}
+}
+
+// WithHTTPClient sends the requests through client, with its own transport,
+// proxy and TLS settings.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.HttpClient = client
+	}
+}
+
+// WithAuth sends value in the header named header on every request, as the
+// esante gateway expects its API key.
+func WithAuth(header string, value string) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.ApiKey = header
+		c.ApiValue = value
+	}
+}
+
+// WithAuthenticator authenticates every request with a, see WithBearerToken
+// and WithClientCredentials for the common cases.
+func WithAuthenticator(a fhirInterface.IAuthenticator) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Authenticator = a
+	}
+}
+
+// WithBearerToken sends a static token as Authorization: Bearer.
+func WithBearerToken(token string) Option {
+	return WithAuthenticator(clients_r4.NewBearerAuthenticator(token))
+}
+
+// WithClientCredentials obtains bearer tokens from tokenUrl through the
+// OAuth2 client credentials grant, caching them until shortly before they
+// expire. The token requests share the transport of the client.
+func WithClientCredentials(tokenUrl, clientId, clientSecret string, scopes ...string) Option {
+	return WithAuthenticator(clients_r4.NewClientCredentialsAuthenticator(tokenUrl, clientId, clientSecret, scopes...))
+}
+
+// WithSmartBackendServices authenticates with SMART Backend Services: a JWT
+// client assertion signed with key is exchanged for an access token at the
+// token endpoint advertised by fhirBaseUrl/.well-known/smart-configuration,
+// both reached through the transport of the client. Use
+// clients_r4.ParsePrivateKeyPEM to load the key.
+func WithSmartBackendServices(fhirBaseUrl, clientId string, key crypto.Signer, keyId string, scopes ...string) Option {
+	return WithAuthenticator(clients_r4.NewSmartBackendAuthenticator(fhirBaseUrl, clientId, key, keyId, scopes...))
+}
+
+// WithTimeout bounds each request, 30 seconds by default.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Timeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the transport. The other TLS
+// options below build on it.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.TLSConfig = config.Clone()
+	}
+}
+
+// WithClientCertificate presents cert for mutual TLS, load it with
+// clients_r4.LoadClientCertificate (PEM) or clients_r4.LoadPKCS12File.
+func WithClientCertificate(cert tls.Certificate) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		config := tlsConfig(c)
+		config.Certificates = append(config.Certificates, cert)
+	}
+}
+
+// WithRootCAs trusts the authorities of pool instead of the system ones, see
+// clients_r4.LoadCertPool.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		tlsConfig(c).RootCAs = pool
+	}
+}
+
+// WithMinTLSVersion refuses servers below version, e.g. tls.VersionTLS12.
+func WithMinTLSVersion(version uint16) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		tlsConfig(c).MinVersion = version
+	}
+}
+
+func tlsConfig(c *fhirInterface.ClientConfig) *tls.Config {
+	if c.TLSConfig == nil {
+		c.TLSConfig = &tls.Config{}
+	}
+	return c.TLSConfig
+}
+
+// WithProxy sends every request through the proxy at proxyUrl.
+func WithProxy(proxyUrl *url.URL) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Proxy = http.ProxyURL(proxyUrl)
+	}
+}
+
+// WithProxyFromEnvironment uses the proxy given by HTTPS_PROXY, HTTP_PROXY
+// and NO_PROXY.
+func WithProxyFromEnvironment() Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Proxy = http.ProxyFromEnvironment
+	}
+}
+
+// WithPageSize sets the number of entries requested per page through _count.
+func WithPageSize(size int) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.EntryLimit = size
+	}
+}
+
+// WithUserAgent sends userAgent as the User-Agent header of every request.
+func WithUserAgent(userAgent string) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.UserAgent = userAgent
+	}
+}
+
+// WithServerProfile adapts the client to a server flavor: base path,
+// pagination, default headers and supported parameters. The esante annuaire
+// v2 profile is used by default.
+func WithServerProfile(profile fhirInterface.ServerProfile) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Profile = &profile
+	}
+}
+
+// WithoutPathRewrite keeps the base URL as given, instead of appending the
+// suffix of the server profile (/v2 for the esante annuaire).
+func WithoutPathRewrite() Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.DisablePathRewrite = true
+	}
+}
+
+// WithMiddleware wraps the transport of the client, the first middleware
+// being the outermost. See also IClient.OnRequest and IClient.OnResponse.
+func WithMiddleware(middlewares ...fhirInterface.Middleware) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Middlewares = append(c.Middlewares, middlewares...)
+	}
+}
+
+// WithRetryPolicy retries failed calls, see fhirInterface.DefaultRetryPolicy.
+func WithRetryPolicy(policy fhirInterface.RetryPolicy) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.RetryPolicy = policy
+	}
+}
+
+// WithRateLimit paces the client to requestsPerSecond on average with bursts
+// of up to burst requests, shared by every goroutine using it. The limiter
+// also pauses when the server reports an exhausted quota.
+func WithRateLimit(requestsPerSecond float64, burst int) Option {
+	return WithRateLimiter(clients_r4.NewRateLimiter(requestsPerSecond, burst))
+}
+
+// WithRateLimiter paces the client with a custom limiter, which may be
+// shared between several clients hitting the same quota.
+func WithRateLimiter(limiter fhirInterface.IRateLimiter) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.RateLimiter = limiter
+	}
+}
+
+// WithCache keeps GET responses in cache, see clients_r4.NewMemoryCache and
+// clients_r4.NewDiskCache. Entries younger than ttl are served without any
+// request, older ones are revalidated with If-None-Match / If-Modified-Since.
+func WithCache(cache fhirInterface.ICache, ttl time.Duration) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Cache = cache
+		c.CacheTTL = ttl
+	}
+}
+
+// WithLogger routes the client diagnostics (requests, status codes, timings,
+// retries) to logger. The client is silent by default.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *fhirInterface.ClientConfig) {
+		c.Logger = logger
+	}
+}
@@ -0,0 +1,266 @@
+package fhir
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+	clients_r4 "github.com/LGMorgan/go-fhir/versions/r4/clients"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+const emptyBundle = `{"resourceType":"Bundle","type":"searchset"}`
+
+// authority signs the client certificates, as an IGC-Santé one would.
+type authority struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newAuthority(t *testing.T) authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority{cert: cert, key: key}
+}
+
+func (a authority) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(a.cert)
+	return pool
+}
+
+func (a authority) issueClient(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.cert, &key.PublicKey, a.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func writePEM(t *testing.T, name string, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newMutualTLSServer answers an empty bundle to the clients presenting a
+// certificate of ca, config completing its TLS configuration.
+func newMutualTLSServer(t *testing.T, ca authority, config func(*tls.Config)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(emptyBundle))
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  ca.pool(),
+	}
+	if config != nil {
+		config(server.TLS)
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+// serverCAFile writes the certificate of server to a PEM file, to be trusted
+// through LoadCertPool.
+func serverCAFile(t *testing.T, server *httptest.Server) string {
+	return writePEM(t, "server.pem", "CERTIFICATE", server.Certificate().Raw)
+}
+
+func get(client fhirInterface.IClient) error {
+	_, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{})
+	return err
+}
+
+func testOptions(opts ...Option) []Option {
+	return append([]Option{
+		WithServerProfile(fhirInterface.GENERIC_PROFILE),
+		WithRetryPolicy(fhirInterface.RetryPolicy{}),
+		WithTimeout(5 * time.Second),
+	}, opts...)
+}
+
+func TestMutualTLSWithPEMFiles(t *testing.T) {
+	ca := newAuthority(t)
+	server := newMutualTLSServer(t, ca, nil)
+	cert, key := ca.issueClient(t)
+	keyDer, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := clients_r4.LoadClientCertificate(
+		writePEM(t, "client.pem", "CERTIFICATE", cert.Raw),
+		writePEM(t, "client.key", "PRIVATE KEY", keyDer))
+	if err != nil {
+		t.Fatalf("LoadClientCertificate: %v", err)
+	}
+	pool, err := clients_r4.LoadCertPool(serverCAFile(t, server))
+	if err != nil {
+		t.Fatalf("LoadCertPool: %v", err)
+	}
+
+	client := New(server.URL, testOptions(WithClientCertificate(clientCert), WithRootCAs(pool))...)
+	if err := get(client); err != nil {
+		t.Errorf("request with the client certificate: %v", err)
+	}
+}
+
+func TestMutualTLSWithPKCS12(t *testing.T) {
+	ca := newAuthority(t)
+	server := newMutualTLSServer(t, ca, nil)
+	cert, key := ca.issueClient(t)
+	data, err := pkcs12.Modern.Encode(key, cert, []*x509.Certificate{ca.cert}, "changeit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "client.p12")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := clients_r4.LoadPKCS12File(path, "wrong"); err == nil {
+		t.Error("LoadPKCS12File succeeded with a wrong password")
+	}
+	clientCert, err := clients_r4.LoadPKCS12File(path, "changeit")
+	if err != nil {
+		t.Fatalf("LoadPKCS12File: %v", err)
+	}
+	if len(clientCert.Certificate) != 2 {
+		t.Errorf("chain of %d certificates, want the client and its CA", len(clientCert.Certificate))
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+	client := New(server.URL, testOptions(WithClientCertificate(clientCert), WithRootCAs(pool))...)
+	if err := get(client); err != nil {
+		t.Errorf("request with the PKCS#12 certificate: %v", err)
+	}
+}
+
+func TestMutualTLSFailures(t *testing.T) {
+	ca := newAuthority(t)
+	server := newMutualTLSServer(t, ca, func(config *tls.Config) {
+		config.MaxVersion = tls.VersionTLS12
+	})
+	cert, key := ca.issueClient(t)
+	clientCert := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+	}
+	trusted := x509.NewCertPool()
+	trusted.AddCert(server.Certificate())
+
+	tests := []struct {
+		name string
+		opts []Option
+	}{
+		{
+			name: "without a client certificate",
+			opts: []Option{WithRootCAs(trusted)},
+		},
+		{
+			name: "with an untrusted server",
+			opts: []Option{WithClientCertificate(clientCert), WithRootCAs(ca.pool())},
+		},
+		{
+			name: "below the minimum TLS version",
+			opts: []Option{WithClientCertificate(clientCert), WithRootCAs(trusted), WithMinTLSVersion(tls.VersionTLS13)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := get(New(server.URL, testOptions(tt.opts...)...)); err == nil {
+				t.Error("request succeeded")
+			}
+		})
+	}
+
+	// The same client succeeds once every requirement is met
+	client := New(server.URL, testOptions(WithClientCertificate(clientCert), WithRootCAs(trusted), WithMinTLSVersion(tls.VersionTLS12))...)
+	if err := get(client); err != nil {
+		t.Errorf("request meeting the requirements: %v", err)
+	}
+}
+
+func TestLoadCertPoolErrors(t *testing.T) {
+	if _, err := clients_r4.LoadCertPool(); err == nil {
+		t.Error("LoadCertPool succeeded without a file")
+	}
+	notPEM := filepath.Join(t.TempDir(), "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0o600)
+	if _, err := clients_r4.LoadCertPool(notPEM); err == nil {
+		t.Error("LoadCertPool succeeded without a certificate in the file")
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.URL.String())
+		w.Write([]byte(emptyBundle))
+	}))
+	defer proxy.Close()
+	proxyUrl, _ := url.Parse(proxy.URL)
+
+	client := New("http://fhir.invalid", testOptions(WithProxy(proxyUrl))...)
+	if err := get(client); err != nil {
+		t.Fatalf("request through the proxy: %v", err)
+	}
+	if len(proxied) != 1 || proxied[0] != "http://fhir.invalid/Organization" {
+		t.Errorf("proxy received %v, want http://fhir.invalid/Organization", proxied)
+	}
+}
@@ -0,0 +1,204 @@
+package clients_r4
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+const (
+	DEFAULT_TOKEN_REFRESH_BEFORE = 60 * time.Second
+)
+
+// ApiKeyAuthenticator sends a static value in a header, as the esante
+// gateway expects its ESANTE-API-KEY.
+type ApiKeyAuthenticator struct {
+	Header string
+	Value  string
+}
+
+func NewApiKeyAuthenticator(header, value string) *ApiKeyAuthenticator {
+	return &ApiKeyAuthenticator{
+		Header: header,
+		Value:  value,
+	}
+}
+
+func (a *ApiKeyAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set(a.Header, a.Value)
+	return nil
+}
+
+// BearerAuthenticator sends a static token as Authorization: Bearer.
+type BearerAuthenticator struct {
+	Token string
+}
+
+func NewBearerAuthenticator(token string) *BearerAuthenticator {
+	return &BearerAuthenticator{
+		Token: token,
+	}
+}
+
+func (a *BearerAuthenticator) Authenticate(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+	return nil
+}
+
+// ClientCredentialsAuthenticator obtains its bearer token through the
+// OAuth2 client credentials grant. The token is cached and renewed
+// RefreshBefore its expiry.
+type ClientCredentialsAuthenticator struct {
+	TokenUrl      string
+	ClientId      string
+	ClientSecret  string
+	Scopes        []string
+	RefreshBefore time.Duration
+	// HttpClient is used to reach the token endpoint. When nil, the FHIR client
+	// given the authenticator sets its own transport and timeout, and
+	// http.DefaultClient is used outside of a client.
+	HttpClient *http.Client
+
+	token cachedToken
+}
+
+func NewClientCredentialsAuthenticator(tokenUrl, clientId, clientSecret string, scopes ...string) *ClientCredentialsAuthenticator {
+	return &ClientCredentialsAuthenticator{
+		TokenUrl:      tokenUrl,
+		ClientId:      clientId,
+		ClientSecret:  clientSecret,
+		Scopes:        scopes,
+		RefreshBefore: DEFAULT_TOKEN_REFRESH_BEFORE,
+	}
+}
+
+func (a *ClientCredentialsAuthenticator) Authenticate(req *http.Request) error {
+	token, err := a.token.get(a.RefreshBefore, func() (*tokenResponse, error) {
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		if len(a.Scopes) > 0 {
+			form.Set("scope", strings.Join(a.Scopes, " "))
+		}
+		tokenReq, err := http.NewRequestWithContext(req.Context(), "POST", a.TokenUrl, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		tokenReq.SetBasicAuth(url.QueryEscape(a.ClientId), url.QueryEscape(a.ClientSecret))
+		return requestToken(a.HttpClient, tokenReq)
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token, the next request fetches a new one.
+func (a *ClientCredentialsAuthenticator) Invalidate() {
+	a.token.invalidate()
+}
+
+// ChainAuthenticator applies several authenticators to the same request,
+// e.g. a gateway API key on top of a bearer token.
+type ChainAuthenticator []fhirInterface.IAuthenticator
+
+func NewChainAuthenticator(authenticators ...fhirInterface.IAuthenticator) ChainAuthenticator {
+	return ChainAuthenticator(authenticators)
+}
+
+func (c ChainAuthenticator) Authenticate(req *http.Request) error {
+	for _, a := range c {
+		if err := a.Authenticate(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c ChainAuthenticator) Invalidate() {
+	for _, a := range c {
+		if invalidator, ok := a.(interface{ Invalidate() }); ok {
+			invalidator.Invalidate()
+		}
+	}
+}
+
+// cachedToken keeps an access token until refreshBefore its expiry.
+type cachedToken struct {
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+func (c *cachedToken) get(refreshBefore time.Duration, fetch func() (*tokenResponse, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.token == "" || (!c.expiry.IsZero() && !time.Now().Add(refreshBefore).Before(c.expiry)) {
+		token, err := fetch()
+		if err != nil {
+			return "", err
+		}
+		c.token = token.AccessToken
+		c.expiry = token.expiry()
+	}
+	return c.token, nil
+}
+
+func (c *cachedToken) invalidate() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = ""
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// expiry is zero when the server gives no lifetime: the token is then kept
+// until the FHIR server rejects it.
+func (t tokenResponse) expiry() time.Time {
+	if t.ExpiresIn <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+// requestToken posts a form to an OAuth2 token endpoint.
+func requestToken(client *http.Client, req *http.Request) (*tokenResponse, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fhir: token endpoint %s: %s: %s", req.URL, res.Status, body)
+	}
+	token := &tokenResponse{}
+	if err := json.Unmarshal(body, token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("fhir: token endpoint %s returned no access_token", req.URL)
+	}
+	return token, nil
+}
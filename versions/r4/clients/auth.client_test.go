@@ -0,0 +1,212 @@
+package clients_r4
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// tokenEndpoint stands in for an OAuth2 token endpoint, issuing token-1,
+// token-2... valid expiresIn seconds.
+type tokenEndpoint struct {
+	*httptest.Server
+	expiresIn int
+	issued    atomic.Int32
+}
+
+func newTokenEndpoint(t *testing.T, expiresIn int) *tokenEndpoint {
+	e := newUnstartedTokenEndpoint(expiresIn)
+	e.Start()
+	t.Cleanup(e.Close)
+	return e
+}
+
+func newUnstartedTokenEndpoint(expiresIn int) *tokenEndpoint {
+	e := &tokenEndpoint{
+		expiresIn: expiresIn,
+	}
+	e.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// RFC 6749 form-encodes the credentials before the basic auth
+		clientId, clientSecret, ok := r.BasicAuth()
+		clientId, _ = url.QueryUnescape(clientId)
+		clientSecret, _ = url.QueryUnescape(clientSecret)
+		switch {
+		case r.Method != http.MethodPost:
+			http.Error(w, "POST expected", http.StatusMethodNotAllowed)
+			return
+		case !ok || clientId != "client" || clientSecret != "s3cr&t":
+			http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+			return
+		case r.FormValue("grant_type") != "client_credentials":
+			http.Error(w, `{"error":"unsupported_grant_type"}`, http.StatusBadRequest)
+			return
+		case r.FormValue("scope") != "system/*.read system/Practitioner.read":
+			http.Error(w, `{"error":"invalid_scope"}`, http.StatusBadRequest)
+			return
+		}
+		n := e.issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   e.expiresIn,
+		})
+	}))
+	return e
+}
+
+func (e *tokenEndpoint) authenticator() *ClientCredentialsAuthenticator {
+	return NewClientCredentialsAuthenticator(e.URL, "client", "s3cr&t", "system/*.read", "system/Practitioner.read")
+}
+
+func authorization(t *testing.T, a fhirInterface.IAuthenticator) string {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "http://fhir.invalid/Practitioner", nil)
+	if err := a.Authenticate(req); err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	return req.Header.Get("Authorization")
+}
+
+func TestStaticAuthenticators(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://fhir.invalid/Practitioner", nil)
+	NewChainAuthenticator(NewApiKeyAuthenticator("ESANTE-API-KEY", "key"), NewBearerAuthenticator("abc")).Authenticate(req)
+	if got := req.Header.Get("ESANTE-API-KEY"); got != "key" {
+		t.Errorf("ESANTE-API-KEY = %q, want key", got)
+	}
+	if got := req.Header.Get("Authorization"); got != "Bearer abc" {
+		t.Errorf("Authorization = %q, want Bearer abc", got)
+	}
+}
+
+func TestClientCredentialsCachesToken(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 3600)
+	a := endpoint.authenticator()
+	for i := 0; i < 3; i++ {
+		if got := authorization(t, a); got != "Bearer token-1" {
+			t.Fatalf("Authorization = %q, want Bearer token-1", got)
+		}
+	}
+	if n := endpoint.issued.Load(); n != 1 {
+		t.Errorf("%d tokens issued, want 1", n)
+	}
+}
+
+func TestClientCredentialsRefreshesBeforeExpiry(t *testing.T) {
+	// Expiring within RefreshBefore, the token is renewed on every request
+	endpoint := newTokenEndpoint(t, 30)
+	a := endpoint.authenticator()
+	a.RefreshBefore = time.Minute
+	authorization(t, a)
+	if got := authorization(t, a); got != "Bearer token-2" {
+		t.Errorf("Authorization = %q, want Bearer token-2", got)
+	}
+}
+
+func TestClientCredentialsInvalidate(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 3600)
+	a := endpoint.authenticator()
+	authorization(t, a)
+	a.Invalidate()
+	if got := authorization(t, a); got != "Bearer token-2" {
+		t.Errorf("Authorization = %q, want Bearer token-2", got)
+	}
+}
+
+func TestClientCredentialsEndpointError(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 3600)
+	a := NewClientCredentialsAuthenticator(endpoint.URL, "client", "wrong")
+	req := httptest.NewRequest(http.MethodGet, "http://fhir.invalid/Practitioner", nil)
+	if err := a.Authenticate(req); err == nil {
+		t.Fatal("Authenticate succeeded with a wrong secret")
+	}
+}
+
+func TestClientRenewsRevokedToken(t *testing.T) {
+	endpoint := newTokenEndpoint(t, 3600)
+	// The FHIR server only accepts the second token, as if the first had been revoked
+	var authorizations []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorizations = append(authorizations, r.Header.Get("Authorization"))
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"resourceType":"Bundle","type":"searchset"}`))
+	}))
+	defer server.Close()
+
+	profile := fhirInterface.GENERIC_PROFILE
+	client := NewFhirClientWithConfig(server.URL, fhirInterface.ClientConfig{
+		Authenticator: endpoint.authenticator(),
+		Profile:       &profile,
+	})
+	if _, err := client.GetRaw("/Practitioner", fhirInterface.UrlParameters{}); err != nil {
+		t.Fatalf("GetRaw: %v", err)
+	}
+	want := []string{"Bearer token-1", "Bearer token-2"}
+	if fmt.Sprint(authorizations) != fmt.Sprint(want) {
+		t.Errorf("Authorization sent %v, want %v", authorizations, want)
+	}
+}
+
+func TestTokenRequestsShareClientTransport(t *testing.T) {
+	// Both servers are only trusted through the RootCAs of the client
+	endpoint := newUnstartedTokenEndpoint(3600)
+	endpoint.StartTLS()
+	defer endpoint.Close()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"resourceType":"Bundle","type":"searchset"}`))
+	}))
+	defer server.Close()
+	pool := x509.NewCertPool()
+	pool.AddCert(server.Certificate())
+
+	var middlewarePaths []string
+	profile := fhirInterface.GENERIC_PROFILE
+	client := NewFhirClientWithConfig(server.URL, fhirInterface.ClientConfig{
+		TLSConfig:     &tls.Config{RootCAs: pool},
+		ApiKey:        "ESANTE-API-KEY",
+		ApiValue:      "key",
+		Authenticator: endpoint.authenticator(),
+		Profile:       &profile,
+		Middlewares: []fhirInterface.Middleware{
+			func(next http.RoundTripper) http.RoundTripper {
+				return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					middlewarePaths = append(middlewarePaths, req.URL.Path)
+					return next.RoundTrip(req)
+				})
+			},
+		},
+	})
+	if _, err := client.GetRaw("/Practitioner", fhirInterface.UrlParameters{}); err != nil {
+		t.Fatalf("GetRaw: %v", err)
+	}
+	if n := endpoint.issued.Load(); n != 1 {
+		t.Errorf("%d tokens issued, want 1", n)
+	}
+	if fmt.Sprint(middlewarePaths) != "[/Practitioner]" {
+		t.Errorf("middlewares saw %v, want only the FHIR request", middlewarePaths)
+	}
+}
+
+func TestOwnHttpClientIsKept(t *testing.T) {
+	own := &http.Client{}
+	a := NewClientCredentialsAuthenticator("http://token.invalid", "client", "secret")
+	a.HttpClient = own
+	NewFhirClientWithConfig("http://fhir.invalid", fhirInterface.ClientConfig{
+		Authenticator: a,
+	})
+	if a.HttpClient != own {
+		t.Error("HttpClient of the authenticator replaced by the client one")
+	}
+}
@@ -0,0 +1,222 @@
+package clients_r4
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+const (
+	DEFAULT_MEMORY_CACHE_SIZE = 1000
+)
+
+// doCached serves GETs from the client Cache: fresh entries (younger than
+// CacheTTL) are returned without any request, older ones are revalidated
+// with If-None-Match / If-Modified-Since. A fresh entry never reaches the
+// middlewares nor the RateLimiter.
+func (f *fhir) doCached(req *http.Request) (*http.Response, error) {
+	if f.Cache == nil || req.Method != http.MethodGet {
+		return f.do(req)
+	}
+	key := req.URL.String()
+	entry, cached := f.Cache.Get(key)
+	if cached && f.CacheTTL > 0 && time.Since(entry.StoredAt) < f.CacheTTL {
+		f.Logger.Debug("cache hit", "url", key)
+		return cachedResponse(req, entry), nil
+	}
+	if cached {
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+
+	res, err := f.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if cached && res.StatusCode == http.StatusNotModified {
+		f.Logger.Debug("cache revalidated", "url", key)
+		io.Copy(io.Discard, res.Body)
+		res.Body.Close()
+		entry.StoredAt = time.Now()
+		f.Cache.Set(key, entry)
+		return cachedResponse(req, entry), nil
+	}
+	if res.StatusCode != http.StatusOK || strings.Contains(res.Header.Get("Cache-Control"), "no-store") {
+		return res, nil
+	}
+	entry = fhirInterface.CacheEntry{
+		ETag:         res.Header.Get("ETag"),
+		LastModified: res.Header.Get("Last-Modified"),
+		StoredAt:     time.Now(),
+	}
+	if entry.ETag == "" && entry.LastModified == "" && f.CacheTTL <= 0 {
+		// Nothing to revalidate with and no TTL, the entry would never be used
+		return res, nil
+	}
+	entry.Body, err = io.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	f.Cache.Set(key, entry)
+	res.Body = io.NopCloser(bytes.NewReader(entry.Body))
+	return res, nil
+}
+
+func cachedResponse(req *http.Request, entry fhirInterface.CacheEntry) *http.Response {
+	header := http.Header{}
+	header.Set("Content-Type", "application/fhir+json")
+	if entry.ETag != "" {
+		header.Set("ETag", entry.ETag)
+	}
+	if entry.LastModified != "" {
+		header.Set("Last-Modified", entry.LastModified)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(entry.Body)),
+		ContentLength: int64(len(entry.Body)),
+		Request:       req,
+	}
+}
+
+// MemoryCache keeps up to Size entries in memory, evicting the least
+// recently used one.
+type MemoryCache struct {
+	Size int
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+type memoryCacheItem struct {
+	key   string
+	entry fhirInterface.CacheEntry
+}
+
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DEFAULT_MEMORY_CACHE_SIZE
+	}
+	return &MemoryCache{
+		Size:    size,
+		order:   list.New(),
+		entries: map[string]*list.Element{},
+	}
+}
+
+func (c *MemoryCache) Get(key string) (fhirInterface.CacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return fhirInterface.CacheEntry{}, false
+	}
+	c.order.MoveToFront(element)
+	return element.Value.(*memoryCacheItem).entry, true
+}
+
+func (c *MemoryCache) Set(key string, entry fhirInterface.CacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		element.Value.(*memoryCacheItem).entry = entry
+		c.order.MoveToFront(element)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&memoryCacheItem{key: key, entry: entry})
+	for c.order.Len() > c.Size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*memoryCacheItem).key)
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[key]; ok {
+		c.order.Remove(element)
+		delete(c.entries, key)
+	}
+}
+
+// DiskCache stores one JSON file per entry in Dir, so it survives restarts
+// and can be shared by successive runs of a daily job.
+type DiskCache struct {
+	Dir string
+}
+
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &DiskCache{
+		Dir: dir,
+	}, nil
+}
+
+func (c *DiskCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(sum[:])+".json")
+}
+
+func (c *DiskCache) Get(key string) (fhirInterface.CacheEntry, bool) {
+	entry := fhirInterface.CacheEntry{}
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return entry, false
+	}
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return entry, false
+	}
+	return entry, true
+}
+
+func (c *DiskCache) Set(key string, entry fhirInterface.CacheEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	// Write then rename so a concurrent Get never reads a partial file
+	tmp, err := os.CreateTemp(c.Dir, "entry-*")
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+func (c *DiskCache) Delete(key string) {
+	os.Remove(c.path(key))
+}
@@ -0,0 +1,221 @@
+package clients_r4
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+const lastModified = "Mon, 05 Oct 2026 08:00:00 GMT"
+
+// versionedServer answers the version of an Organization, with its ETag
+// and/or Last-Modified, and a 304 when the client already has it.
+type versionedServer struct {
+	*httptest.Server
+	version      atomic.Int32
+	hits         atomic.Int32
+	notModified  atomic.Int32
+	etag         bool
+	lastModified bool
+	noStore      bool
+}
+
+func newVersionedServer(t *testing.T, etag, lastModified, noStore bool) *versionedServer {
+	s := &versionedServer{etag: etag, lastModified: lastModified, noStore: noStore}
+	s.version.Store(1)
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *versionedServer) serve(w http.ResponseWriter, r *http.Request) {
+	s.hits.Add(1)
+	version := s.version.Load()
+	etag := fmt.Sprintf(`W/"%d"`, version)
+	// The organization changes after lastModified with version 2
+	unchanged := (s.etag && r.Header.Get("If-None-Match") == etag) ||
+		(!s.etag && s.lastModified && version == 1 && r.Header.Get("If-Modified-Since") == lastModified)
+	if unchanged {
+		s.notModified.Add(1)
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if s.etag {
+		w.Header().Set("ETag", etag)
+	}
+	if s.lastModified && version == 1 {
+		w.Header().Set("Last-Modified", lastModified)
+	}
+	if s.noStore {
+		w.Header().Set("Cache-Control", "no-store")
+	}
+	fmt.Fprintf(w, `{"resourceType":"Organization","id":"org-1","meta":{"versionId":"%d"}}`, version)
+}
+
+func cachingClient(url string, cache fhirInterface.ICache, ttl time.Duration) fhirInterface.IClient {
+	profile := fhirInterface.GENERIC_PROFILE
+	return NewFhirClientWithConfig(url, fhirInterface.ClientConfig{
+		Profile:  &profile,
+		Cache:    cache,
+		CacheTTL: ttl,
+	})
+}
+
+func getOrganization(t *testing.T, client fhirInterface.IClient) string {
+	t.Helper()
+	body, err := client.GetRaw("/Organization/org-1", fhirInterface.UrlParameters{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(body)
+}
+
+func TestCache(t *testing.T) {
+	version1 := `{"resourceType":"Organization","id":"org-1","meta":{"versionId":"1"}}`
+	version2 := `{"resourceType":"Organization","id":"org-1","meta":{"versionId":"2"}}`
+	tests := []struct {
+		name         string
+		etag         bool
+		lastModified bool
+		noStore      bool
+		ttl          time.Duration
+		// changed bumps the version before the second request
+		changed     bool
+		want        string
+		hits        int32
+		notModified int32
+	}{
+		{name: "fresh entry", etag: true, ttl: time.Hour, want: version1, hits: 1},
+		{name: "fresh entry without validator", ttl: time.Hour, want: version1, hits: 1},
+		{name: "fresh entry hides a change", etag: true, ttl: time.Hour, changed: true, want: version1, hits: 1},
+		{name: "expired, revalidated by ETag", etag: true, ttl: time.Millisecond, want: version1, hits: 2, notModified: 1},
+		{name: "expired, revalidated by date", lastModified: true, ttl: time.Millisecond, want: version1, hits: 2, notModified: 1},
+		{name: "expired and changed", etag: true, ttl: time.Millisecond, changed: true, want: version2, hits: 2},
+		{name: "no TTL, revalidated", etag: true, want: version1, hits: 2, notModified: 1},
+		{name: "no TTL and no validator", changed: true, want: version2, hits: 2},
+		{name: "no-store", etag: true, noStore: true, ttl: time.Hour, want: version1, hits: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newVersionedServer(t, tt.etag, tt.lastModified, tt.noStore)
+			client := cachingClient(server.URL, NewMemoryCache(10), tt.ttl)
+			if got := getOrganization(t, client); got != version1 {
+				t.Fatalf("first read %s, want version 1", got)
+			}
+			time.Sleep(5 * time.Millisecond)
+			if tt.changed {
+				server.version.Store(2)
+			}
+			if got := getOrganization(t, client); got != tt.want {
+				t.Errorf("second read %s, want %s", got, tt.want)
+			}
+			if got := server.hits.Load(); got != tt.hits {
+				t.Errorf("%d requests, want %d", got, tt.hits)
+			}
+			if got := server.notModified.Load(); got != tt.notModified {
+				t.Errorf("%d 304, want %d", got, tt.notModified)
+			}
+		})
+	}
+}
+
+func TestCacheRevalidationRefreshesEntry(t *testing.T) {
+	server := newVersionedServer(t, true, false, false)
+	client := cachingClient(server.URL, NewMemoryCache(10), 50*time.Millisecond)
+	getOrganization(t, client)
+	time.Sleep(60 * time.Millisecond)
+	// Revalidated by a 304, the entry is fresh again for another TTL
+	getOrganization(t, client)
+	getOrganization(t, client)
+	if hits, notModified := server.hits.Load(), server.notModified.Load(); hits != 2 || notModified != 1 {
+		t.Errorf("%d requests and %d 304, want the read after the 304 served from cache", hits, notModified)
+	}
+}
+
+func TestMemoryCacheEviction(t *testing.T) {
+	cache := NewMemoryCache(2)
+	for _, key := range []string{"a", "b"} {
+		cache.Set(key, fhirInterface.CacheEntry{ETag: key})
+	}
+	// a is now the most recently used, b goes first
+	cache.Get("a")
+	cache.Set("c", fhirInterface.CacheEntry{ETag: "c"})
+	if _, ok := cache.Get("b"); ok {
+		t.Error("b kept, want the least recently used entry evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if entry, ok := cache.Get(key); !ok || entry.ETag != key {
+			t.Errorf("%s: %+v, %v, want it kept", key, entry, ok)
+		}
+	}
+	// Updating an entry doesn't grow the cache
+	cache.Set("a", fhirInterface.CacheEntry{ETag: "a2"})
+	if entry, ok := cache.Get("c"); !ok || entry.ETag != "c" {
+		t.Error("c evicted by an update of a")
+	}
+	cache.Delete("a")
+	if _, ok := cache.Get("a"); ok {
+		t.Error("a still cached after Delete")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "https://gateway.api.esante.gouv.fr/fhir/v2/Organization/org-1"
+	entry := fhirInterface.CacheEntry{
+		Body:         []byte(`{"resourceType":"Organization","id":"org-1"}`),
+		ETag:         `W/"1"`,
+		LastModified: lastModified,
+		StoredAt:     time.Now().Round(0),
+	}
+	cache.Set(key, entry)
+
+	// Another run finds the entry on disk
+	reopened, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := reopened.Get(key)
+	if !ok {
+		t.Fatal("entry not found after reopening the cache")
+	}
+	if !got.StoredAt.Equal(entry.StoredAt) {
+		t.Errorf("stored at %s, want %s", got.StoredAt, entry.StoredAt)
+	}
+	got.StoredAt = entry.StoredAt
+	if !reflect.DeepEqual(got, entry) {
+		t.Errorf("entry %+v, want %+v", got, entry)
+	}
+	if _, ok := reopened.Get(key + "?_count=10"); ok {
+		t.Error("entry found under another key")
+	}
+	reopened.Delete(key)
+	if _, ok := cache.Get(key); ok {
+		t.Error("entry still on disk after Delete")
+	}
+}
+
+func TestDiskCacheAcrossClients(t *testing.T) {
+	server := newVersionedServer(t, true, false, false)
+	dir := t.TempDir()
+	for run := 0; run < 2; run++ {
+		cache, err := NewDiskCache(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		getOrganization(t, cachingClient(server.URL, cache, time.Hour))
+	}
+	if got := server.hits.Load(); got != 1 {
+		t.Errorf("%d requests, want the second run served from disk", got)
+	}
+}
@@ -2,9 +2,11 @@ package clients_r4
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
@@ -20,107 +22,305 @@ const (
 )
 
 type fhir struct {
-	Client     http.Client
-	BaseURL    string
-	ApiKey     string
-	ApiValue   string
-	EntryLimit int
+	Client http.Client
+	// Transport is the transport of Client before any middleware
+	Transport     http.RoundTripper
+	Middlewares   []fhirInterface.Middleware
+	BaseURL       string
+	ApiKey        string
+	Authenticator fhirInterface.IAuthenticator
+	UserAgent     string
+	Profile       fhirInterface.ServerProfile
+	EntryLimit    int
+	RetryPolicy   fhirInterface.RetryPolicy
+	RateLimiter   fhirInterface.IRateLimiter
+	Cache         fhirInterface.ICache
+	CacheTTL      time.Duration
+	Logger        *slog.Logger
 }
 
 func NewFhirClient(baseURL, apiKey, apiValue string) fhirInterface.IClient {
-	clientHttp := &http.Client{
+	return NewFhirClientWithConfig(baseURL, fhirInterface.ClientConfig{
+		ApiKey:   apiKey,
+		ApiValue: apiValue,
+	})
+}
+
+func NewFhirClientWithConfig(baseURL string, config fhirInterface.ClientConfig) fhirInterface.IClient {
+	clientHttp := http.Client{
 		Timeout: DEFAULT_TIMEOUT * time.Second,
 		Transport: &http.Transport{
 			MaxIdleConns:        0,
 			MaxIdleConnsPerHost: 10,
 		},
 	}
-	// Append /v2 to the base URL for API v2
-	if !strings.HasSuffix(baseURL, "/v2") {
-		baseURL = baseURL + "/v2"
+	if config.HttpClient != nil {
+		clientHttp = *config.HttpClient
+	}
+	if config.Timeout > 0 {
+		clientHttp.Timeout = config.Timeout
+	}
+	logger := config.Logger
+	if logger == nil {
+		logger = newDiscardLogger()
+	}
+	if config.TLSConfig != nil || config.Proxy != nil {
+		var transport *http.Transport
+		switch t := clientHttp.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport).Clone()
+		case *http.Transport:
+			transport = t.Clone()
+		}
+		if transport != nil {
+			if config.TLSConfig != nil {
+				transport.TLSClientConfig = config.TLSConfig
+			}
+			if config.Proxy != nil {
+				transport.Proxy = config.Proxy
+			}
+			clientHttp.Transport = transport
+		} else {
+			logger.Warn("TLS and proxy options ignored, the HTTP client transport is not an *http.Transport")
+		}
+	}
+	entryLimit := DEFAULT_ENTRY_LIMIT
+	if config.EntryLimit > 0 {
+		entryLimit = config.EntryLimit
+	}
+	authenticator := config.Authenticator
+	if config.ApiKey != "" {
+		apiKeyAuthenticator := NewApiKeyAuthenticator(config.ApiKey, config.ApiValue)
+		if authenticator == nil {
+			authenticator = apiKeyAuthenticator
+		} else {
+			// e.g. a gateway API key on top of a SMART or OAuth2 token
+			authenticator = NewChainAuthenticator(apiKeyAuthenticator, authenticator)
+		}
+	}
+	// Remember the API key header so its value is redacted from the logs
+	apiKey := config.ApiKey
+	if a, ok := authenticator.(*ApiKeyAuthenticator); ok {
+		apiKey = a.Header
+	}
+	profile := fhirInterface.ESANTE_V2_PROFILE
+	if config.Profile != nil {
+		profile = *config.Profile
+	}
+	// Append the profile suffix to the base URL, e.g. /v2 for the esante annuaire
+	if !config.DisablePathRewrite && profile.BasePathSuffix != "" && !strings.HasSuffix(baseURL, profile.BasePathSuffix) {
+		baseURL = baseURL + profile.BasePathSuffix
 	}
-	return &fhir{
-		Client:     *clientHttp,
-		BaseURL:    baseURL,
-		ApiKey:     apiKey,
-		ApiValue:   apiValue,
-		EntryLimit: DEFAULT_ENTRY_LIMIT,
+	f := &fhir{
+		Client:        clientHttp,
+		Transport:     clientHttp.Transport,
+		BaseURL:       baseURL,
+		ApiKey:        apiKey,
+		Authenticator: authenticator,
+		UserAgent:     config.UserAgent,
+		Profile:       profile,
+		EntryLimit:    entryLimit,
+		RetryPolicy:   config.RetryPolicy,
+		RateLimiter:   config.RateLimiter,
+		Cache:         config.Cache,
+		CacheTTL:      config.CacheTTL,
+		Logger:        logger,
 	}
+	// Token and discovery requests go to the same gateway, through the same
+	// TLS and proxy settings, but not through the middlewares
+	shareHttpClient(authenticator, &http.Client{
+		Transport: f.Transport,
+		Timeout:   clientHttp.Timeout,
+	})
+	if len(config.Middlewares) > 0 {
+		f.Use(config.Middlewares...)
+	}
+	return f
 }
 
-func (f *fhir) call(method string, path *url.URL, payload []byte, res interface{}) error {
+// shareHttpClient gives client to the authenticators of a lacking their own
+// HttpClient.
+func shareHttpClient(a fhirInterface.IAuthenticator, client *http.Client) {
+	switch a := a.(type) {
+	case *ClientCredentialsAuthenticator:
+		if a.HttpClient == nil {
+			a.HttpClient = client
+		}
+	case *SmartBackendAuthenticator:
+		if a.HttpClient == nil {
+			a.HttpClient = client
+		}
+	case ChainAuthenticator:
+		for _, each := range a {
+			shareHttpClient(each, client)
+		}
+	}
+}
 
-	fmt.Println("\t\t\t\t\t", "-->", method, ":", f.BaseURL+path.String())
+// buildUrl resolves uri against the base URL with the parameters, failing on
+// those the profile doesn't support.
+// An absolute uri, such as a next link, is used verbatim as long as it points
+// to the same server.
+func (f *fhir) buildUrl(uri string, values url.Values) (string, error) {
+	if strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://") {
+		if !sameOrigin(uri, f.BaseURL) {
+			return "", fmt.Errorf("fhir: refusing to follow %s outside of %s", uri, f.BaseURL)
+		}
+		return uri, nil
+	}
+	for name := range values {
+		if f.Profile.SupportsParameter(name) {
+			continue
+		}
+		// Without _count the server uses its own page size, the results are the same
+		if name == "_count" {
+			f.Logger.Debug("_count not supported by the server, dropped", "profile", f.Profile.Name)
+			values.Del(name)
+			continue
+		}
+		return "", fmt.Errorf("%w: %s (profile %s)", fhirInterface.ErrUnsupportedParameter, name, f.Profile.Name)
+	}
+	path := &url.URL{
+		Path:     uri,
+		RawQuery: values.Encode(),
+	}
+	return f.BaseURL + path.String(), nil
+}
 
-	req, err := http.NewRequest(method, f.BaseURL+path.String(), bytes.NewBuffer(payload))
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
 	if err != nil {
-		return err
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return ua.Scheme == ub.Scheme && ua.Host == ub.Host
+}
+
+// newRequest builds a request with the headers every call sends.
+func (f *fhir) newRequest(ctx context.Context, method string, rawUrl string, payload []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawUrl, bytes.NewBuffer(payload))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range f.Profile.DefaultHeaders {
+		req.Header.Set(name, value)
 	}
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json")
-	req.Header.Set(f.ApiKey, f.ApiValue)
+	if f.UserAgent != "" {
+		req.Header.Set("User-Agent", f.UserAgent)
+	}
+	return req, nil
+}
 
-	response, err := f.Client.Do(req)
+func (f *fhir) call(ctx context.Context, method string, rawUrl string, payload []byte, res interface{}) error {
+	req, err := f.newRequest(ctx, method, rawUrl, payload)
 	if err != nil {
 		return err
 	}
-	if response != nil {
-		defer response.Body.Close()
+
+	response, err := f.doCached(req)
+	if err != nil {
+		return err
 	}
+	defer response.Body.Close()
 
-	if response.StatusCode == 200 {
-		err = json.NewDecoder(response.Body).Decode(res)
-		if err != nil {
-			return err
-		}
+	if err := checkResponse(req, response); err != nil {
+		return err
 	}
-	return nil
+	return json.NewDecoder(response.Body).Decode(res)
+}
+
+// checkResponse turns any non-2xx answer into a *fhirInterface.HttpError
+// carrying the response body and the OperationOutcome it may contain.
+func checkResponse(req *http.Request, res *http.Response) error {
+	if res.StatusCode >= 200 && res.StatusCode < 300 {
+		return nil
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	httpErr := &fhirInterface.HttpError{
+		Method:     req.Method,
+		Url:        req.URL.String(),
+		StatusCode: res.StatusCode,
+		Status:     res.Status,
+		Body:       body,
+	}
+	outcome := &models_r4.OperationOutcome{}
+	if json.Unmarshal(body, outcome) == nil && outcome.ResourceType == "OperationOutcome" {
+		httpErr.Outcome = outcome.Err()
+	}
+	return httpErr
+}
+
+func (f *fhir) GetLogger() *slog.Logger {
+	return f.Logger
+}
+
+// SetLogger routes the client diagnostics to logger, nil silences them.
+func (f *fhir) SetLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = newDiscardLogger()
+	}
+	f.Logger = logger
 }
 
 func (f *fhir) GetBaseUrl() string {
 	return f.BaseURL
 }
 
+func (f *fhir) GetProfile() fhirInterface.ServerProfile {
+	return f.Profile
+}
+
 func (f *fhir) GetRaw(uri string, p fhirInterface.UrlParameters) ([]byte, error) {
-	values := p.BuildUrlValues()
-	path := &url.URL{
-		Path:     uri,
-		RawQuery: values.Encode(),
-	}
+	return f.GetRawContext(context.Background(), uri, p)
+}
 
-	fmt.Println( /*"\t\t\t\t\t",*/ "--> GetRAW:", f.BaseURL+path.String())
-	req, err := http.NewRequest("GET", f.BaseURL+path.String(), nil)
+func (f *fhir) GetRawContext(ctx context.Context, uri string, p fhirInterface.UrlParameters) ([]byte, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
+	rawUrl, err := f.buildUrl(uri, p.BuildUrlValues())
 	if err != nil {
 		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set(f.ApiKey, f.ApiValue)
 
-	res, err := f.Client.Do(req)
+	req, err := f.newRequest(ctx, "GET", rawUrl, nil)
 	if err != nil {
 		return nil, err
 	}
-	if res != nil {
-		defer res.Body.Close()
+
+	res, err := f.doCached(req)
+	if err != nil {
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	if res.StatusCode == 200 {
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return nil, err
-		}
-		return body, nil
+	if err := checkResponse(req, res); err != nil {
+		return nil, err
 	}
-	return nil, nil
+	return io.ReadAll(res.Body)
 }
 
 func (f *fhir) Get(uri string, p fhirInterface.UrlParameters, resType fhirInterface.ResourceType) (fhirInterface.IResourceResult, error) {
+	return f.GetContext(context.Background(), uri, p, resType)
+}
+
+func (f *fhir) GetContext(ctx context.Context, uri string, p fhirInterface.UrlParameters, resType fhirInterface.ResourceType) (fhirInterface.IResourceResult, error) {
+	if p.Err != nil {
+		return nil, p.Err
+	}
 	values := p.BuildUrlValues()
 	values.Add("_count", fmt.Sprintf("%d", f.EntryLimit))
-	path := &url.URL{
-		Path:     uri,
-		RawQuery: values.Encode(),
+	rawUrl, err := f.buildUrl(uri, values)
+	if err != nil {
+		return nil, err
 	}
 
 	switch resType {
@@ -128,31 +328,29 @@ func (f *fhir) Get(uri string, p fhirInterface.UrlParameters, resType fhirInterf
 		res := &models_r4.BundleResult{
 			Client: f,
 		}
-		err := f.call("GET", path, nil, res)
+		err := f.call(ctx, "GET", rawUrl, nil, res)
 		if err != nil {
 			return nil, err
 		}
 		return res, nil
 	}
 
-	return nil, nil
+	return nil, fmt.Errorf("fhir: unsupported resource type %q", resType)
 }
 
 func (f *fhir) Search(r fhirInterface.ResourceType) fhirInterface.IResource {
+	f.Logger.Debug("Search", "resource", r)
 	switch r {
 	case fhirInterface.ORGANIZATION:
-		fmt.Println("\t--> Search(Organization)")
 		return &models_r4.Organization{
 			Client: f,
 		}
 	case fhirInterface.PRACTITIONER_ROLE:
-		fmt.Println("\t--> Search(PractitionerRole)")
 		return &models_r4.PractitionerRole{
 			Client: f,
 		}
 
 	case fhirInterface.PRACTITIONER:
-		fmt.Println("\t--> Search(Practitioner)")
 		return &models_r4.Practitioner{
 			Client: f,
 		}
@@ -160,27 +358,20 @@ func (f *fhir) Search(r fhirInterface.ResourceType) fhirInterface.IResource {
 	return nil
 }
 
-func (f *fhir) LoadPage() struct {
-	Next func(fhirInterface.IResourceResult) fhirInterface.IRequest
-} {
-	return struct {
-		Next func(fhirInterface.IResourceResult) fhirInterface.IRequest
-	}{
-		Next: func(res fhirInterface.IResourceResult) fhirInterface.IRequest {
-			fmt.Println("\t\t\t\t--> LoadNextPage")
-			req, err := res.MakeRequestNextPage()
-			if err != nil {
-				return nil
-			}
-			return req
-		},
-	}
+func (f *fhir) LoadPage() fhirInterface.PageLoader {
+	return fhirInterface.NewPageLoader(f.Logger, func(cursor fhirInterface.Cursor) fhirInterface.IResourceResult {
+		return models_r4.BundleFromCursor(f, cursor)
+	})
 }
 
 func (f *fhir) SetEntryLimit(limit int) {
 	f.EntryLimit = limit
 }
 
+func (f *fhir) SetRetryPolicy(policy fhirInterface.RetryPolicy) {
+	f.RetryPolicy = policy
+}
+
 func (f *fhir) SetTimeout(timeout int) {
 	f.Client.Timeout = time.Duration(timeout) * time.Second
 }
@@ -0,0 +1,36 @@
+package clients_r4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+func TestInvalidQueryIsNotSent(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Write([]byte(`{"resourceType":"Bundle","type":"searchset"}`))
+	}))
+	defer server.Close()
+	profile := fhirInterface.GENERIC_PROFILE
+	client := NewFhirClientWithConfig(server.URL, fhirInterface.ClientConfig{
+		Profile: &profile,
+	})
+
+	// name=cab OR address-postalcode=974 can't be expressed in FHIR
+	params := fhirInterface.FhirName{}.StartsWith().Value("cab").Union(fhirInterface.FhirAddress{}.StartsWith().Value("974"))
+	if _, err := client.GetRaw("/Organization", params); !errors.Is(err, fhirInterface.ErrOrAcrossParameters) {
+		t.Errorf("GetRaw error %v, want ErrOrAcrossParameters", err)
+	}
+	if _, err := client.GetContext(context.Background(), "/Organization", params, fhirInterface.BUNDLE); !errors.Is(err, fhirInterface.ErrOrAcrossParameters) {
+		t.Errorf("GetContext error %v, want ErrOrAcrossParameters", err)
+	}
+	if requests != 0 {
+		t.Errorf("%d requests sent, want none", requests)
+	}
+}
@@ -0,0 +1,46 @@
+package clients_r4
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+)
+
+const REDACTED = "REDACTED"
+
+// discardHandler drops every record, it keeps the client silent unless a
+// logger is given.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+func newDiscardLogger() *slog.Logger {
+	return slog.New(discardHandler{})
+}
+
+func newRequestId() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
+
+// redactHeaders flattens the headers for logging, hiding the credentials.
+func (f *fhir) redactHeaders(header http.Header) map[string]string {
+	values := make(map[string]string, len(header))
+	for name := range header {
+		if http.CanonicalHeaderKey(name) == http.CanonicalHeaderKey(f.ApiKey) ||
+			name == "Authorization" || name == "Proxy-Authorization" {
+			values[name] = REDACTED
+			continue
+		}
+		values[name] = header.Get(name)
+	}
+	return values
+}
@@ -0,0 +1,47 @@
+package clients_r4
+
+import (
+	"net/http"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// Use wraps the transport with middlewares, the first one being the
+// outermost. Call it before issuing requests. The middlewares see the
+// requests sent over the network only, not the fresh cache hits.
+func (f *fhir) Use(middlewares ...fhirInterface.Middleware) {
+	f.Middlewares = append(f.Middlewares, middlewares...)
+	transport := f.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(f.Middlewares) - 1; i >= 0; i-- {
+		transport = f.Middlewares[i](transport)
+	}
+	f.Client.Transport = transport
+}
+
+// OnRequest calls hook on every request before it is sent, e.g. to add a
+// correlation header. Like the other middlewares it skips the fresh cache
+// hits.
+func (f *fhir) OnRequest(hook func(*http.Request)) {
+	f.Use(func(next http.RoundTripper) http.RoundTripper {
+		return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			hook(req)
+			return next.RoundTrip(req)
+		})
+	})
+}
+
+// OnResponse calls hook on every response received, whatever its status.
+func (f *fhir) OnResponse(hook func(*http.Response)) {
+	f.Use(func(next http.RoundTripper) http.RoundTripper {
+		return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			res, err := next.RoundTrip(req)
+			if err == nil {
+				hook(res)
+			}
+			return res, err
+		})
+	})
+}
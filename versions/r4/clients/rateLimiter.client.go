@@ -0,0 +1,146 @@
+package clients_r4
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token bucket shared by every goroutine using the client.
+// It also pauses itself when the server reports an exhausted quota through
+// RateLimit-* / X-RateLimit-* headers or a 429 Retry-After.
+type RateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	// last is the instant tokens were last refilled, it is pushed into the
+	// future while the server asks us to pause.
+	last time.Time
+}
+
+// NewRateLimiter allows requestsPerSecond on average, with bursts of up to
+// burst requests. A rate of 0 only follows the pauses asked by the server.
+func NewRateLimiter(requestsPerSecond float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:   requestsPerSecond,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+func (l *RateLimiter) refill(now time.Time) {
+	if now.Before(l.last) {
+		return
+	}
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+}
+
+func (l *RateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	var wait time.Duration
+	// A pause asked by the server holds even without a rate
+	if l.last.After(now) {
+		wait = l.last.Sub(now)
+	}
+	if l.rate > 0 {
+		l.refill(now)
+		// Reserve a token, going into debt if needed: the debt tells how long to wait
+		l.tokens--
+		if l.tokens < 0 {
+			wait += time.Duration(-l.tokens / l.rate * float64(time.Second))
+		}
+	}
+	l.mu.Unlock()
+
+	for wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if l.rate > 0 {
+				l.mu.Lock()
+				l.tokens++
+				l.mu.Unlock()
+			}
+			return ctx.Err()
+		case <-timer.C:
+		}
+		// The server may have asked for a pause while we were waiting
+		l.mu.Lock()
+		wait = time.Until(l.last)
+		l.mu.Unlock()
+	}
+	return nil
+}
+
+func (l *RateLimiter) Observe(res *http.Response) {
+	if res == nil {
+		return
+	}
+	remaining, hasRemaining := headerInt(res.Header, "RateLimit-Remaining", "X-RateLimit-Remaining")
+	var pause time.Duration
+	switch {
+	case res.StatusCode == http.StatusTooManyRequests:
+		if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+			pause = retryAfter
+		} else if reset, ok := rateLimitReset(res.Header); ok {
+			pause = reset
+		}
+	case hasRemaining && remaining <= 0:
+		if reset, ok := rateLimitReset(res.Header); ok {
+			pause = reset
+		}
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	l.refill(now)
+	if hasRemaining && float64(remaining) < l.tokens {
+		l.tokens = float64(remaining)
+	}
+	if pause > 0 {
+		if l.tokens > 0 {
+			l.tokens = 0
+		}
+		if resume := now.Add(pause); resume.After(l.last) {
+			l.last = resume
+		}
+	}
+}
+
+// rateLimitReset reads the delay before the quota is reset. Servers send
+// either a number of seconds or a unix timestamp.
+func rateLimitReset(header http.Header) (time.Duration, bool) {
+	reset, ok := headerInt(header, "RateLimit-Reset", "X-RateLimit-Reset")
+	if !ok || reset < 0 {
+		return 0, false
+	}
+	if reset > 1_000_000_000 {
+		return time.Until(time.Unix(int64(reset), 0)), true
+	}
+	return time.Duration(reset) * time.Second, true
+}
+
+func headerInt(header http.Header, names ...string) (int, bool) {
+	for _, name := range names {
+		if value := header.Get(name); value != "" {
+			if i, err := strconv.Atoi(value); err == nil {
+				return i, true
+			}
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,127 @@
+package clients_r4
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// waitWithin calls Wait with a timeout, failing with context.DeadlineExceeded
+// when the limiter holds the request longer.
+func waitWithin(l *RateLimiter, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return l.Wait(ctx)
+}
+
+func response(status int, header ...string) *http.Response {
+	res := &http.Response{StatusCode: status, Header: http.Header{}}
+	for i := 0; i+1 < len(header); i += 2 {
+		res.Header.Set(header[i], header[i+1])
+	}
+	return res
+}
+
+func TestRateLimiterPacing(t *testing.T) {
+	limiter := NewRateLimiter(50, 2)
+	start := time.Now()
+	for i := 0; i < 2; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 15*time.Millisecond {
+		t.Errorf("burst of 2 took %s, want no wait", elapsed)
+	}
+	for i := 0; i < 3; i++ {
+		if err := limiter.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 3 requests past the burst at 50 per second
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond || elapsed > time.Second {
+		t.Errorf("5 requests took %s, want about 60ms", elapsed)
+	}
+}
+
+func TestRateLimiterCancelGivesTokenBack(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	if err := limiter.Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := waitWithin(limiter, 10*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("error %v, want the empty bucket to hold the request", err)
+	}
+	limiter.mu.Lock()
+	defer limiter.mu.Unlock()
+	if limiter.tokens < -0.5 {
+		t.Errorf("%.2f tokens, want the cancelled reservation given back", limiter.tokens)
+	}
+}
+
+func TestRateLimiterObserve(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  float64
+		res   *http.Response
+		pause bool
+	}{
+		{"success", 100, response(http.StatusOK), false},
+		{"quota left", 100, response(http.StatusOK, "RateLimit-Remaining", "3", "RateLimit-Reset", "60"), false},
+		{"429 with Retry-After", 100, response(http.StatusTooManyRequests, "Retry-After", "60"), true},
+		{"429 with RateLimit-Reset", 100, response(http.StatusTooManyRequests, "RateLimit-Reset", "60"), true},
+		{"429 with a reset timestamp", 100, response(http.StatusTooManyRequests, "X-RateLimit-Reset", strconv.FormatInt(time.Now().Add(time.Minute).Unix(), 10)), true},
+		{"quota exhausted", 100, response(http.StatusOK, "X-RateLimit-Remaining", "0", "X-RateLimit-Reset", "60"), true},
+		{"429 without rate", 0, response(http.StatusTooManyRequests, "Retry-After", "60"), true},
+		{"quota exhausted without rate", 0, response(http.StatusOK, "RateLimit-Remaining", "0", "RateLimit-Reset", "60"), true},
+		{"429 without delay", 0, response(http.StatusTooManyRequests), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(tt.rate, 10)
+			limiter.Observe(tt.res)
+			err := waitWithin(limiter, 20*time.Millisecond)
+			if paused := errors.Is(err, context.DeadlineExceeded); paused != tt.pause {
+				t.Errorf("Wait error %v, want a pause %v", err, tt.pause)
+			}
+		})
+	}
+}
+
+func TestRateLimiterObserveRemaining(t *testing.T) {
+	limiter := NewRateLimiter(10, 10)
+	limiter.Observe(response(http.StatusOK, "RateLimit-Remaining", "1"))
+	if err := waitWithin(limiter, 20*time.Millisecond); err != nil {
+		t.Fatalf("last request of the quota: %v", err)
+	}
+	if err := waitWithin(limiter, 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the bucket emptied down to the remaining quota", err)
+	}
+}
+
+func TestClientFollowsServerPause(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+	profile := fhirInterface.GENERIC_PROFILE
+	client := NewFhirClientWithConfig(server.URL, fhirInterface.ClientConfig{
+		Profile:     &profile,
+		RateLimiter: NewRateLimiter(0, 1),
+	})
+	if _, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{}); err == nil {
+		t.Fatal("429 succeeded")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetRawContext(ctx, "/Organization", fhirInterface.UrlParameters{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error %v, want the request held by the Retry-After", err)
+	}
+}
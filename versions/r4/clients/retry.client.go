@@ -0,0 +1,143 @@
+package clients_r4
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// do sends the request, paced by the client RateLimiter and retried
+// according to its RetryPolicy.
+func (f *fhir) do(req *http.Request) (*http.Response, error) {
+	policy := f.RetryPolicy
+	maxAttempts := policy.MaxAttempts
+	if maxAttempts < 1 || !policy.AllowsMethod(req.Method) {
+		maxAttempts = 1
+	}
+	logger := f.Logger.With("request_id", newRequestId(), "method", req.Method, "url", req.URL.String())
+
+	sent, reauthenticated := false, false
+	for attempt := 1; ; attempt++ {
+		if sent && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		if f.Authenticator != nil {
+			if err := f.Authenticator.Authenticate(req); err != nil {
+				return nil, err
+			}
+		}
+		if f.RateLimiter != nil {
+			if err := f.RateLimiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+		logger.Debug("request", "attempt", attempt, "headers", f.redactHeaders(req.Header))
+		start := time.Now()
+		res, err := f.Client.Do(req)
+		sent = true
+		if err != nil {
+			logger.Error("request failed", "attempt", attempt, "duration", time.Since(start), "error", err)
+		} else {
+			level := slog.LevelDebug
+			if res.StatusCode >= 400 {
+				level = slog.LevelWarn
+			}
+			logger.Log(req.Context(), level, "response", "attempt", attempt, "status", res.StatusCode, "duration", time.Since(start))
+		}
+		if f.RateLimiter != nil && res != nil {
+			f.RateLimiter.Observe(res)
+		}
+		// A cached token may have been revoked before its expiry: drop it and
+		// try once more with a fresh one
+		if invalidator, ok := f.Authenticator.(interface{ Invalidate() }); ok && err == nil &&
+			res.StatusCode == http.StatusUnauthorized && !reauthenticated {
+			reauthenticated = true
+			invalidator.Invalidate()
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			attempt--
+			continue
+		}
+		if attempt >= maxAttempts {
+			return res, err
+		}
+
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if !policy.RetryNetworkErrors || !isRetryableError(req, err) {
+				return nil, err
+			}
+			wait = policy.Backoff(attempt)
+		case policy.IsRetryableStatus(res.StatusCode):
+			wait = policy.Backoff(attempt)
+			if retryAfter, ok := parseRetryAfter(res.Header.Get("Retry-After")); ok {
+				// Waiting longer than MaxBackoff is left to the caller, which
+				// gets the response and its Retry-After
+				if policy.MaxBackoff > 0 && retryAfter > policy.MaxBackoff {
+					logger.Warn("Retry-After exceeds the max backoff, giving up", "retry_after", retryAfter, "max_backoff", policy.MaxBackoff)
+					return res, nil
+				}
+				wait = retryAfter
+			}
+			// Drain the body so the connection can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		default:
+			return res, nil
+		}
+
+		logger.Info("retrying", "attempt", attempt, "wait", wait)
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// isRetryableError tells transient network failures apart from errors that
+// would happen again, such as a cancelled context or an invalid certificate.
+func isRetryableError(req *http.Request, err error) bool {
+	if req.Context().Err() != nil {
+		return false
+	}
+	var certErr *tls.CertificateVerificationError
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	if errors.As(err, &certErr) || errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) {
+		return false
+	}
+	return true
+}
+
+// parseRetryAfter reads a Retry-After header, given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,163 @@
+package clients_r4
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// flakyServer fails the first failures requests with status and retryAfter,
+// or by closing the connection when status is 0, then answers a Bundle.
+type flakyServer struct {
+	*httptest.Server
+	attempts atomic.Int32
+	bodies   []string
+}
+
+func newFlakyServer(t *testing.T, failures int32, status int, retryAfter string) *flakyServer {
+	s := &flakyServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.bodies = append(s.bodies, string(body))
+		if s.attempts.Add(1) <= failures {
+			if status == 0 {
+				conn, _, _ := w.(http.Hijacker).Hijack()
+				conn.Close()
+				return
+			}
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.Write([]byte(`{"resourceType":"Bundle","type":"searchset"}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// retryingClient retries every status the default policy does, waiting
+// initialBackoff then twice as long... up to a second.
+func retryingClient(url string, maxAttempts int, initialBackoff time.Duration) *fhir {
+	profile := fhirInterface.GENERIC_PROFILE
+	policy := fhirInterface.DefaultRetryPolicy()
+	policy.MaxAttempts = maxAttempts
+	policy.InitialBackoff = initialBackoff
+	policy.MaxBackoff = time.Second
+	policy.Jitter = 0
+	return NewFhirClientWithConfig(url, fhirInterface.ClientConfig{
+		Profile:     &profile,
+		RetryPolicy: policy,
+	}).(*fhir)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		failures    int32
+		status      int
+		retryAfter  string
+		maxAttempts int
+		attempts    int32
+		wantStatus  int
+	}{
+		{name: "success", maxAttempts: 4, attempts: 1},
+		{name: "503 twice", failures: 2, status: http.StatusServiceUnavailable, maxAttempts: 4, attempts: 3},
+		{name: "429 with Retry-After", failures: 2, status: http.StatusTooManyRequests, retryAfter: "0", maxAttempts: 4, attempts: 3},
+		{name: "network errors", failures: 2, maxAttempts: 4, attempts: 3},
+		{name: "out of attempts", failures: 5, status: http.StatusServiceUnavailable, maxAttempts: 3, attempts: 3, wantStatus: http.StatusServiceUnavailable},
+		{name: "status not retryable", failures: 1, status: http.StatusInternalServerError, maxAttempts: 4, attempts: 1, wantStatus: http.StatusInternalServerError},
+		{name: "Retry-After above the max backoff", failures: 1, status: http.StatusTooManyRequests, retryAfter: "120", maxAttempts: 4, attempts: 1, wantStatus: http.StatusTooManyRequests},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := newFlakyServer(t, tt.failures, tt.status, tt.retryAfter)
+			client := retryingClient(server.URL, tt.maxAttempts, time.Millisecond)
+			_, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{})
+			if got := server.attempts.Load(); got != tt.attempts {
+				t.Errorf("%d attempts, want %d", got, tt.attempts)
+			}
+			httpErr := &fhirInterface.HttpError{}
+			switch {
+			case tt.wantStatus == 0 && err != nil:
+				t.Errorf("error %v, want success", err)
+			case tt.wantStatus != 0 && (!errors.As(err, &httpErr) || httpErr.StatusCode != tt.wantStatus):
+				t.Errorf("error %v, want an HttpError %d", err, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestRetryAfterReplacesBackoff(t *testing.T) {
+	server := newFlakyServer(t, 1, http.StatusServiceUnavailable, "0")
+	client := retryingClient(server.URL, 2, time.Minute)
+	start := time.Now()
+	if _, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("retried after %s, want Retry-After: 0 honoured over the backoff", elapsed)
+	}
+}
+
+func TestBackoffGrows(t *testing.T) {
+	server := newFlakyServer(t, 3, http.StatusBadGateway, "")
+	client := retryingClient(server.URL, 4, 20*time.Millisecond)
+	start := time.Now()
+	if _, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{}); err != nil {
+		t.Fatal(err)
+	}
+	// 20ms, 40ms then 80ms
+	if elapsed := time.Since(start); elapsed < 140*time.Millisecond {
+		t.Errorf("3 retries in %s, want at least 140ms of backoff", elapsed)
+	}
+}
+
+func TestRetryNonIdempotent(t *testing.T) {
+	for _, allowed := range []bool{false, true} {
+		server := newFlakyServer(t, 1, http.StatusServiceUnavailable, "")
+		client := retryingClient(server.URL, 4, time.Millisecond)
+		client.RetryPolicy.RetryNonIdempotent = allowed
+		req, err := client.newRequest(context.Background(), http.MethodPost, server.URL+"/Organization", []byte(`{"resourceType":"Organization"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := client.do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res.Body.Close()
+		want := int32(1)
+		if allowed {
+			want = 2
+		}
+		if got := server.attempts.Load(); got != want {
+			t.Errorf("RetryNonIdempotent %v: %d POSTs, want %d", allowed, got, want)
+		}
+		for i, body := range server.bodies {
+			if body != `{"resourceType":"Organization"}` {
+				t.Errorf("RetryNonIdempotent %v: attempt %d sent %q, want the payload again", allowed, i+1, body)
+			}
+		}
+	}
+}
+
+func TestNetworkErrorsNotRetried(t *testing.T) {
+	server := newFlakyServer(t, 1, 0, "")
+	client := retryingClient(server.URL, 4, time.Millisecond)
+	client.RetryPolicy.RetryNetworkErrors = false
+	if _, err := client.GetRaw("/Organization", fhirInterface.UrlParameters{}); err == nil {
+		t.Error("closed connection succeeded")
+	}
+	if got := server.attempts.Load(); got != 1 {
+		t.Errorf("%d attempts, want 1 without RetryNetworkErrors", got)
+	}
+}
@@ -0,0 +1,248 @@
+package clients_r4
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	CLIENT_ASSERTION_TYPE     = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+	CLIENT_ASSERTION_LIFETIME = 5 * time.Minute
+	SMART_CONFIGURATION_PATH  = "/.well-known/smart-configuration"
+)
+
+// SmartBackendAuthenticator implements SMART Backend Services: it signs a JWT
+// client assertion with the client private key (RS384, or ES256/ES384/ES512
+// depending on the curve), exchanges it at the token endpoint and caches the
+// access token. The token endpoint is discovered from the
+// .well-known/smart-configuration of FhirBaseUrl when TokenUrl is empty.
+type SmartBackendAuthenticator struct {
+	FhirBaseUrl string
+	TokenUrl    string
+	ClientId    string
+	// Key signs the client assertion, *rsa.PrivateKey and *ecdsa.PrivateKey
+	// are supported, as well as any crypto.Signer holding such a key.
+	Key crypto.Signer
+	// KeyId is sent as the kid of the JWT header, matching the published JWKS.
+	KeyId         string
+	Scopes        []string
+	RefreshBefore time.Duration
+	// HttpClient is used for discovery and token requests. When nil, the FHIR
+	// client given the authenticator sets its own transport and timeout, and
+	// http.DefaultClient is used outside of a client.
+	HttpClient *http.Client
+
+	discoverMu sync.Mutex
+	token      cachedToken
+}
+
+func NewSmartBackendAuthenticator(fhirBaseUrl, clientId string, key crypto.Signer, keyId string, scopes ...string) *SmartBackendAuthenticator {
+	return &SmartBackendAuthenticator{
+		FhirBaseUrl:   fhirBaseUrl,
+		ClientId:      clientId,
+		Key:           key,
+		KeyId:         keyId,
+		Scopes:        scopes,
+		RefreshBefore: DEFAULT_TOKEN_REFRESH_BEFORE,
+	}
+}
+
+func (a *SmartBackendAuthenticator) Authenticate(req *http.Request) error {
+	token, err := a.token.get(a.RefreshBefore, func() (*tokenResponse, error) {
+		tokenUrl, err := a.tokenEndpoint(req)
+		if err != nil {
+			return nil, err
+		}
+		assertion, err := a.clientAssertion(tokenUrl)
+		if err != nil {
+			return nil, err
+		}
+		form := url.Values{}
+		form.Set("grant_type", "client_credentials")
+		form.Set("scope", strings.Join(a.Scopes, " "))
+		form.Set("client_assertion_type", CLIENT_ASSERTION_TYPE)
+		form.Set("client_assertion", assertion)
+		tokenReq, err := http.NewRequestWithContext(req.Context(), "POST", tokenUrl, strings.NewReader(form.Encode()))
+		if err != nil {
+			return nil, err
+		}
+		return requestToken(a.HttpClient, tokenReq)
+	})
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// Invalidate drops the cached token, the next request fetches a new one.
+func (a *SmartBackendAuthenticator) Invalidate() {
+	a.token.invalidate()
+}
+
+// tokenEndpoint returns TokenUrl, discovering it on first use.
+func (a *SmartBackendAuthenticator) tokenEndpoint(req *http.Request) (string, error) {
+	a.discoverMu.Lock()
+	defer a.discoverMu.Unlock()
+	if a.TokenUrl != "" {
+		return a.TokenUrl, nil
+	}
+	if a.FhirBaseUrl == "" {
+		return "", errors.New("fhir: SMART backend services needs a TokenUrl or a FhirBaseUrl to discover it")
+	}
+	discoveryUrl := strings.TrimSuffix(a.FhirBaseUrl, "/") + SMART_CONFIGURATION_PATH
+	discoveryReq, err := http.NewRequestWithContext(req.Context(), "GET", discoveryUrl, nil)
+	if err != nil {
+		return "", err
+	}
+	discoveryReq.Header.Set("Accept", "application/json")
+	client := a.HttpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(discoveryReq)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fhir: SMART discovery %s: %s", discoveryUrl, res.Status)
+	}
+	configuration := struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&configuration); err != nil {
+		return "", err
+	}
+	if configuration.TokenEndpoint == "" {
+		return "", fmt.Errorf("fhir: SMART discovery %s: no token_endpoint", discoveryUrl)
+	}
+	a.TokenUrl = configuration.TokenEndpoint
+	return a.TokenUrl, nil
+}
+
+// clientAssertion builds the signed JWT authenticating the client at tokenUrl.
+func (a *SmartBackendAuthenticator) clientAssertion(tokenUrl string) (string, error) {
+	alg, hash, err := signingAlgorithm(a.Key)
+	if err != nil {
+		return "", err
+	}
+	jti := make([]byte, 16)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	header := map[string]string{
+		"alg": alg,
+		"typ": "JWT",
+	}
+	if a.KeyId != "" {
+		header["kid"] = a.KeyId
+	}
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": a.ClientId,
+		"sub": a.ClientId,
+		"aud": tokenUrl,
+		"exp": now.Add(CLIENT_ASSERTION_LIFETIME).Unix(),
+		"iat": now.Unix(),
+		"jti": hex.EncodeToString(jti),
+	}
+	headerJson, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	claimsJson, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(headerJson) + "." + base64.RawURLEncoding.EncodeToString(claimsJson)
+
+	h := hash.New()
+	h.Write([]byte(signingInput))
+	signature, err := a.Key.Sign(rand.Reader, h.Sum(nil), hash)
+	if err != nil {
+		return "", err
+	}
+	if pub, ok := a.Key.Public().(*ecdsa.PublicKey); ok {
+		// JWS wants r || s, crypto.Signer gives an ASN.1 sequence
+		if signature, err = ecdsaJoseSignature(pub.Curve, signature); err != nil {
+			return "", err
+		}
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func signingAlgorithm(key crypto.Signer) (string, crypto.Hash, error) {
+	if key == nil {
+		return "", 0, errors.New("fhir: SMART backend services needs a private key")
+	}
+	switch pub := key.Public().(type) {
+	case *rsa.PublicKey:
+		return "RS384", crypto.SHA384, nil
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			return "ES256", crypto.SHA256, nil
+		case elliptic.P384():
+			return "ES384", crypto.SHA384, nil
+		case elliptic.P521():
+			return "ES512", crypto.SHA512, nil
+		}
+	}
+	return "", 0, fmt.Errorf("fhir: unsupported key type %T for SMART backend services", key.Public())
+}
+
+func ecdsaJoseSignature(curve elliptic.Curve, der []byte) ([]byte, error) {
+	var sig struct {
+		R, S *big.Int
+	}
+	if _, err := asn1.Unmarshal(der, &sig); err != nil {
+		return nil, err
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	out := make([]byte, 2*size)
+	sig.R.FillBytes(out[:size])
+	sig.S.FillBytes(out[size:])
+	return out, nil
+}
+
+// ParsePrivateKeyPEM reads an RSA or EC private key in PKCS#1, SEC 1 or
+// PKCS#8 PEM form, as generated for a SMART client registration.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("fhir: no PEM block found")
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("fhir: unsupported private key type %T", key)
+	}
+	return signer, nil
+}
@@ -0,0 +1,62 @@
+package clients_r4
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// LoadClientCertificate reads a client certificate and its private key from
+// PEM files, e.g. the server certificate of an IGC-Santé authority.
+func LoadClientCertificate(certFile, keyFile string) (tls.Certificate, error) {
+	return tls.LoadX509KeyPair(certFile, keyFile)
+}
+
+// LoadPKCS12 decodes a .p12/.pfx bundle holding the client certificate, its
+// private key and optionally its intermediate authorities.
+func LoadPKCS12(data []byte, password string) (tls.Certificate, error) {
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	certificate := tls.Certificate{
+		Certificate: [][]byte{cert.Raw},
+		PrivateKey:  key,
+		Leaf:        cert,
+	}
+	for _, ca := range caCerts {
+		certificate.Certificate = append(certificate.Certificate, ca.Raw)
+	}
+	return certificate, nil
+}
+
+func LoadPKCS12File(path, password string) (tls.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return LoadPKCS12(data, password)
+}
+
+// LoadCertPool builds a pool of the authorities found in the PEM files, to
+// trust a private CA. Start from x509.SystemCertPool instead to trust both.
+func LoadCertPool(pemFiles ...string) (*x509.CertPool, error) {
+	if len(pemFiles) == 0 {
+		return nil, errors.New("fhir: no CA file given")
+	}
+	pool := x509.NewCertPool()
+	for _, file := range pemFiles {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("fhir: no certificate found in %s", file)
+		}
+	}
+	return pool, nil
+}
@@ -0,0 +1,175 @@
+package r4
+
+import (
+	"context"
+	"fmt"
+	"iter"
+
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+)
+
+// All runs the search and yields its entries, following the next links until
+// the last page, a limit of opts, or an error. An error is yielded once, with
+// a nil entry, and ends the iteration. WithCheckpoint and WithCursor make a
+// long crawl resumable, WithPrefetch overlaps it with the network.
+//
+//	for entry, err := range request.All(ctx, fhirInterface.WithMaxPages(10)) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (req *Request) All(ctx context.Context, opts ...fhirInterface.IterateOption) iter.Seq2[fhirInterface.IEntry, error] {
+	options := iterateOptions(opts)
+	return func(yield func(fhirInterface.IEntry, error) bool) {
+		results := 0
+		for res, err := range req.Pages(ctx, opts...) {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			for _, entry := range res.GetEntries() {
+				if !yield(entry, nil) {
+					return
+				}
+				results++
+				if options.MaxResults > 0 && results >= options.MaxResults {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Pages runs the search and yields its pages in order, the way All yields
+// entries. MaxResults is left to the caller.
+func (req *Request) Pages(ctx context.Context, opts ...fhirInterface.IterateOption) iter.Seq2[fhirInterface.IResourceResult, error] {
+	options := iterateOptions(opts)
+	return func(yield func(fhirInterface.IResourceResult, error) bool) {
+		pages := req.fetchPages(ctx, options)
+		if options.Prefetch > 0 {
+			pages = prefetch(ctx, req.fetchPages, options)
+		}
+		for res, err := range pages {
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(res, nil) {
+				return
+			}
+			// The page is processed once yield returns, unlike the prefetched ones
+			if options.Checkpoint != nil {
+				if err := options.Checkpoint(res.Cursor()); err != nil {
+					yield(nil, err)
+					return
+				}
+			}
+		}
+	}
+}
+
+// Stream is All delivered on a channel, closed at the end of the iteration.
+// Cancel ctx to stop reading early, otherwise the producing goroutine stays
+// blocked on the channel.
+func (req *Request) Stream(ctx context.Context, opts ...fhirInterface.IterateOption) <-chan fhirInterface.EntryResult {
+	results := make(chan fhirInterface.EntryResult)
+	go func() {
+		defer close(results)
+		for entry, err := range req.All(ctx, opts...) {
+			select {
+			case results <- fhirInterface.EntryResult{Entry: entry, Err: err}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results
+}
+
+func iterateOptions(opts []fhirInterface.IterateOption) fhirInterface.IterateOptions {
+	options := fhirInterface.IterateOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// fetchPages fetches the pages one after the other, from the search or from
+// the cursor of options, up to MaxPages.
+func (req *Request) fetchPages(ctx context.Context, options fhirInterface.IterateOptions) iter.Seq2[fhirInterface.IResourceResult, error] {
+	return func(yield func(fhirInterface.IResourceResult, error) bool) {
+		fetch := req.ExecuteBundleContext
+		if cursor := options.Cursor; cursor != nil {
+			if cursor.Query != req.search() {
+				yield(nil, fmt.Errorf("fhir: cursor taken on %s, not on %s", cursor.Query, req.search()))
+				return
+			}
+			if cursor.Done() {
+				return
+			}
+			fetch = func(ctx context.Context) (fhirInterface.IResourceResult, error) {
+				return req.Client.LoadPage().FromCursor(ctx, *cursor)
+			}
+		}
+		for pages := 1; ; pages++ {
+			res, err := fetch(ctx)
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			if !yield(res, nil) {
+				return
+			}
+			if res.GetNextLink() == "" || (options.MaxPages > 0 && pages >= options.MaxPages) {
+				return
+			}
+			next, err := res.MakeRequestNextPage()
+			if err != nil {
+				yield(nil, err)
+				return
+			}
+			fetch = next.ExecuteBundleContext
+		}
+	}
+}
+
+type fetchedPage struct {
+	res fhirInterface.IResourceResult
+	err error
+}
+
+// prefetch runs fetchPages in a goroutine, up to options.Prefetch pages ahead
+// of the caller. Each next link being only known once its page arrives, the
+// pages are still fetched one at a time, but while the caller processes the
+// previous ones. The requests go through the client, so its RateLimiter
+// still paces them.
+func prefetch(ctx context.Context, fetchPages func(context.Context, fhirInterface.IterateOptions) iter.Seq2[fhirInterface.IResourceResult, error], options fhirInterface.IterateOptions) iter.Seq2[fhirInterface.IResourceResult, error] {
+	return func(yield func(fhirInterface.IResourceResult, error) bool) {
+		ctx, cancel := context.WithCancel(ctx)
+		// The goroutine holds one more page while waiting to send it
+		buffer := make(chan fetchedPage, options.Prefetch-1)
+		stopped := make(chan struct{})
+		defer func() {
+			// Abort the page being fetched and wait for the goroutine
+			cancel()
+			<-stopped
+		}()
+		go func() {
+			defer close(stopped)
+			defer close(buffer)
+			for res, err := range fetchPages(ctx, options) {
+				select {
+				case buffer <- fetchedPage{res, err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		for page := range buffer {
+			if !yield(page.res, page.err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package r4_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/LGMorgan/go-fhir/fhirtest"
+	fhirInterface "github.com/LGMorgan/go-fhir/interface"
+	clients_r4 "github.com/LGMorgan/go-fhir/versions/r4/clients"
+)
+
+// The organizations of the fixtures, in the order the server returns them.
+var allOrganizations = []string{"org-974-001", "org-974-002", "org-974-003", "org-976-001", "org-750-001"}
+
+// searchOrganizations searches the organizations of the fixtures one per
+// page, each request going through middleware when given.
+func searchOrganizations(t *testing.T, middleware fhirInterface.Middleware) fhirInterface.IRequest {
+	t.Helper()
+	srv, err := fhirtest.NewServerFS(fhirtest.Fixtures)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(srv.Close)
+	profile := fhirInterface.GENERIC_PROFILE
+	config := fhirInterface.ClientConfig{
+		Profile:    &profile,
+		EntryLimit: 1,
+	}
+	if middleware != nil {
+		config.Middlewares = []fhirInterface.Middleware{middleware}
+	}
+	client := clients_r4.NewFhirClientWithConfig(srv.URL, config)
+	return client.Search(fhirInterface.ORGANIZATION).Where(fhirInterface.UrlParameters{}).ReturnBundle()
+}
+
+// pageRequests is a middleware counting the page requests, and holding the
+// nth one (1-based) until release is closed or the request is cancelled.
+type pageRequests struct {
+	started   atomic.Int32
+	hold      int32
+	release   chan struct{}
+	cancelled atomic.Bool
+}
+
+func newPageRequests(hold int32) *pageRequests {
+	return &pageRequests{
+		hold:    hold,
+		release: make(chan struct{}),
+	}
+}
+
+func (p *pageRequests) middleware(next http.RoundTripper) http.RoundTripper {
+	return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if p.started.Add(1) == p.hold {
+			select {
+			case <-p.release:
+			case <-req.Context().Done():
+				p.cancelled.Store(true)
+				return nil, req.Context().Err()
+			}
+		}
+		return next.RoundTrip(req)
+	})
+}
+
+func collect(t *testing.T, entries func(func(fhirInterface.IEntry, error) bool)) ([]string, []error) {
+	t.Helper()
+	ids, errs := []string{}, []error{}
+	for entry, err := range entries {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ids = append(ids, entry.GetId())
+	}
+	return ids, errs
+}
+
+// waitFor polls cond for up to a second.
+func waitFor(cond func() bool) bool {
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
+
+func TestPrefetchKeepsPageOrder(t *testing.T) {
+	for _, prefetch := range []int{0, 1, 3, 10} {
+		ids, errs := collect(t, searchOrganizations(t, nil).All(context.Background(), fhirInterface.WithPrefetch(prefetch)))
+		if len(errs) > 0 || !reflect.DeepEqual(ids, allOrganizations) {
+			t.Errorf("prefetch %d: %v, %v, want %v", prefetch, ids, errs, allOrganizations)
+		}
+	}
+}
+
+func TestPrefetchBoundsPagesInFlight(t *testing.T) {
+	for _, prefetch := range []int32{1, 2, 3} {
+		requests := newPageRequests(0)
+		pages := 0
+		for _, err := range searchOrganizations(t, requests.middleware).Pages(context.Background(), fhirInterface.WithPrefetch(int(prefetch))) {
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages++
+			if pages > 1 {
+				continue
+			}
+			// Holding the first page, the goroutine fetches up to prefetch pages ahead
+			want := 1 + prefetch
+			if !waitFor(func() bool { return requests.started.Load() >= want }) {
+				t.Errorf("prefetch %d: %d requests while holding the first page, want %d", prefetch, requests.started.Load(), want)
+			}
+			time.Sleep(20 * time.Millisecond)
+			if got := requests.started.Load(); got != want {
+				t.Errorf("prefetch %d: %d requests while holding the first page, want %d", prefetch, got, want)
+			}
+		}
+		if pages != len(allOrganizations) {
+			t.Errorf("prefetch %d: %d pages, want %d", prefetch, pages, len(allOrganizations))
+		}
+	}
+}
+
+func TestPrefetchBreakCancelsFetch(t *testing.T) {
+	// The second page is held until its request is cancelled
+	requests := newPageRequests(2)
+	for _, err := range searchOrganizations(t, requests.middleware).Pages(context.Background(), fhirInterface.WithPrefetch(2)) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !waitFor(func() bool { return requests.started.Load() == 2 }) {
+			t.Fatal("second page never requested")
+		}
+		break
+	}
+	// Pages waits for its goroutine, which waits for the cancelled request
+	if !requests.cancelled.Load() {
+		t.Error("the page in flight was not cancelled by the break")
+	}
+	if got := requests.started.Load(); got != 2 {
+		t.Errorf("%d requests, want no more after the break", got)
+	}
+}
+
+func TestPrefetchErrorEndsIteration(t *testing.T) {
+	failure := errors.New("network down")
+	for _, prefetch := range []int{0, 2} {
+		var requests atomic.Int32
+		failThird := func(next http.RoundTripper) http.RoundTripper {
+			return fhirInterface.RoundTripperFunc(func(req *http.Request) (*http.Response, error) {
+				if requests.Add(1) == 3 {
+					return nil, failure
+				}
+				return next.RoundTrip(req)
+			})
+		}
+		ids, errs := collect(t, searchOrganizations(t, failThird).All(context.Background(), fhirInterface.WithPrefetch(prefetch)))
+		if !reflect.DeepEqual(ids, allOrganizations[:2]) || len(errs) != 1 || !errors.Is(errs[0], failure) {
+			t.Errorf("prefetch %d: %v then %v, want the first 2 organizations then the failure once", prefetch, ids, errs)
+		}
+		if got := requests.Load(); got != 3 {
+			t.Errorf("prefetch %d: %d requests, want none after the failure", prefetch, got)
+		}
+	}
+}
+
+// fakeOrganizations is a page of the organizations with ids.
+func fakeOrganizations(ids ...string) []byte {
+	entries := []string{}
+	for _, id := range ids {
+		entries = append(entries, fmt.Sprintf(`{"resource":{"resourceType":"Organization","id":%q}}`, id))
+	}
+	return []byte(`{"resourceType":"Bundle","type":"searchset","entry":[` + strings.Join(entries, ",") + `]}`)
+}
+
+var reunion = fhirInterface.FhirAddress{}.StartsWith().Value("974")
+
+// fakeSearch searches org-1 to org-5 over 3 pages of the fake client, failing
+// with failure where the next page would be when failAfter pages are given.
+func fakeSearch(failAfter int, failure error) (*fhirtest.Client, fhirInterface.IRequest) {
+	pages := [][]byte{
+		fakeOrganizations("org-1", "org-2"),
+		fakeOrganizations("org-3", "org-4"),
+		fakeOrganizations("org-5"),
+	}
+	client := fhirtest.NewClient()
+	if failure != nil {
+		pages = pages[:failAfter]
+	}
+	client.Respond(fhirInterface.ORGANIZATION, reunion, pages...)
+	if failure != nil {
+		client.RespondError(fhirInterface.ORGANIZATION, reunion, failure)
+	}
+	return client, client.Search(fhirInterface.ORGANIZATION).Where(reunion).ReturnBundle()
+}
+
+func TestAll(t *testing.T) {
+	failure := errors.New("503 Service Unavailable")
+	tests := []struct {
+		name      string
+		opts      []fhirInterface.IterateOption
+		failAfter int
+		failure   error
+		want      []string
+		queries   int
+	}{
+		{name: "every page", want: []string{"org-1", "org-2", "org-3", "org-4", "org-5"}, queries: 3},
+		{name: "max results within a page", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxResults(3)}, want: []string{"org-1", "org-2", "org-3"}, queries: 2},
+		{name: "max results at a page end", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxResults(2)}, want: []string{"org-1", "org-2"}, queries: 1},
+		{name: "max results above the total", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxResults(10)}, want: []string{"org-1", "org-2", "org-3", "org-4", "org-5"}, queries: 3},
+		{name: "max pages", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxPages(2)}, want: []string{"org-1", "org-2", "org-3", "org-4"}, queries: 2},
+		{name: "first limit reached wins", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxPages(1), fhirInterface.WithMaxResults(5)}, want: []string{"org-1", "org-2"}, queries: 1},
+		{name: "search failing", failAfter: 0, failure: failure, want: []string{}, queries: 1},
+		{name: "next page failing", failAfter: 1, failure: failure, want: []string{"org-1", "org-2"}, queries: 2},
+		{name: "limit reached before the failure", opts: []fhirInterface.IterateOption{fhirInterface.WithMaxResults(2)}, failAfter: 1, failure: failure, want: []string{"org-1", "org-2"}, queries: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, req := fakeSearch(tt.failAfter, tt.failure)
+			ids, errs := collect(t, req.All(context.Background(), tt.opts...))
+			if !reflect.DeepEqual(ids, tt.want) {
+				t.Errorf("entries %v, want %v", ids, tt.want)
+			}
+			wantErrs := 0
+			if tt.failure != nil && len(tt.want) < 5 && tt.queries > tt.failAfter {
+				wantErrs = 1
+			}
+			if len(errs) != wantErrs || (wantErrs == 1 && !errors.Is(errs[0], tt.failure)) {
+				t.Errorf("errors %v, want the failure %d time(s)", errs, wantErrs)
+			}
+			if got := len(client.Queries()); got != tt.queries {
+				t.Errorf("%d queries, want %d", got, tt.queries)
+			}
+		})
+	}
+}
+
+func TestPages(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  []fhirInterface.IterateOption
+		pages int
+	}{
+		{"every page", nil, 3},
+		{"max pages", []fhirInterface.IterateOption{fhirInterface.WithMaxPages(2)}, 2},
+		{"max results left to the caller", []fhirInterface.IterateOption{fhirInterface.WithMaxResults(1)}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, req := fakeSearch(0, nil)
+			pages := 0
+			for _, err := range req.Pages(context.Background(), tt.opts...) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				pages++
+			}
+			if pages != tt.pages {
+				t.Errorf("%d pages, want %d", pages, tt.pages)
+			}
+		})
+	}
+}
+
+// drain reads results until it is closed, failing after a second.
+func drain(t *testing.T, results <-chan fhirInterface.EntryResult) ([]string, []error) {
+	t.Helper()
+	ids, errs := []string{}, []error{}
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return ids, errs
+			}
+			if result.Err != nil {
+				errs = append(errs, result.Err)
+				continue
+			}
+			ids = append(ids, result.Entry.GetId())
+		case <-timeout:
+			t.Fatal("Stream channel not closed")
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	_, req := fakeSearch(0, nil)
+	ids, errs := drain(t, req.Stream(context.Background(), fhirInterface.WithMaxResults(4)))
+	if len(errs) > 0 || !reflect.DeepEqual(ids, []string{"org-1", "org-2", "org-3", "org-4"}) {
+		t.Errorf("Stream gave %v, %v, want the first 4 organizations", ids, errs)
+	}
+
+	failure := errors.New("503 Service Unavailable")
+	_, req = fakeSearch(1, failure)
+	ids, errs = drain(t, req.Stream(context.Background()))
+	if !reflect.DeepEqual(ids, []string{"org-1", "org-2"}) || len(errs) != 1 || !errors.Is(errs[0], failure) {
+		t.Errorf("Stream gave %v then %v, want the first page then the failure once", ids, errs)
+	}
+}
+
+func TestStreamClosesOnCancel(t *testing.T) {
+	_, req := fakeSearch(0, nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	results := req.Stream(ctx)
+	first := <-results
+	if first.Err != nil || first.Entry.GetId() != "org-1" {
+		t.Fatalf("first result %+v, want org-1", first)
+	}
+	// Stop reading: the producer must give up rather than stay blocked
+	cancel()
+	ids, _ := drain(t, results)
+	if len(ids) >= 4 {
+		t.Errorf("%d entries after the cancel, want the iteration stopped", len(ids))
+	}
+}
+
+func TestCheckpointThenResume(t *testing.T) {
+	interrupted := errors.New("interrupted")
+	client, req := fakeSearch(0, nil)
+	var saved []byte
+	checkpoint := func(cursor fhirInterface.Cursor) error {
+		var err error
+		if saved, err = json.Marshal(cursor); err != nil {
+			return err
+		}
+		if cursor.Page == 2 {
+			return interrupted
+		}
+		return nil
+	}
+	first, errs := collect(t, req.All(context.Background(), fhirInterface.WithCheckpoint(checkpoint)))
+	if len(errs) != 1 || !errors.Is(errs[0], interrupted) {
+		t.Fatalf("errors %v, want the checkpoint error once", errs)
+	}
+
+	// A restart reads the cursor back from its JSON
+	cursor := fhirInterface.Cursor{}
+	if err := json.Unmarshal(saved, &cursor); err != nil {
+		t.Fatal(err)
+	}
+	if cursor.Page != 2 || cursor.Done() {
+		t.Fatalf("cursor %+v, want one taken on page 2 with a next link", cursor)
+	}
+	page, err := client.LoadPage().FromCursor(context.Background(), cursor)
+	if err != nil {
+		t.Fatalf("FromCursor: %v", err)
+	}
+	if got := entryIds(page); !reflect.DeepEqual(got, []string{"org-5"}) {
+		t.Errorf("FromCursor page %v, want page 3", got)
+	}
+
+	rest, errs := collect(t, req.All(context.Background(), fhirInterface.WithCursor(cursor)))
+	if len(errs) > 0 {
+		t.Fatalf("resume: %v", errs)
+	}
+	want := []string{"org-1", "org-2", "org-3", "org-4", "org-5"}
+	if got := append(first, rest...); !reflect.DeepEqual(got, want) {
+		t.Errorf("entries %v across both runs, want each of %v once", got, want)
+	}
+}
+
+func TestResumeFromCursor(t *testing.T) {
+	client, req := fakeSearch(0, nil)
+	var last fhirInterface.Cursor
+	for res, err := range req.Pages(context.Background()) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		last = res.Cursor()
+	}
+	if !last.Done() {
+		t.Fatalf("cursor of the last page %+v, want it done", last)
+	}
+	if ids, errs := collect(t, req.All(context.Background(), fhirInterface.WithCursor(last))); len(ids) > 0 || len(errs) > 0 {
+		t.Errorf("resuming a done cursor gave %v, %v, want nothing", ids, errs)
+	}
+	queries := len(client.Queries())
+
+	other := client.Search(fhirInterface.ORGANIZATION).Where(fhirInterface.FhirAddress{}.StartsWith().Value("976")).ReturnBundle()
+	cursor := fhirInterface.Cursor{NextLink: fhirtest.FAKE_BASE_URL + "/_page", Page: 1, Query: last.Query}
+	_, errs := collect(t, other.All(context.Background(), fhirInterface.WithCursor(cursor)))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "cursor taken on") {
+		t.Errorf("errors %v, want the cursor refused on another search", errs)
+	}
+	if got := len(client.Queries()); got != queries {
+		t.Errorf("%d queries sent resuming a done or foreign cursor", got-queries)
+	}
+}
+
+func entryIds(res fhirInterface.IResourceResult) []string {
+	ids := []string{}
+	for _, e := range res.GetEntries() {
+		ids = append(ids, e.GetId())
+	}
+	return ids
+}
@@ -3,6 +3,8 @@ package models_r4
 import (
 	"fmt"
 	"net/url"
+	"strings"
+	"time"
 
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
 	"github.com/LGMorgan/go-fhir/versions/r4"
@@ -22,32 +24,123 @@ type BundleResult struct {
 		Url      string `json:"url"`
 	} `json:"link"`
 	Entry []Entry `json:"entry"`
+	// Query and Page tell which page of which search the Bundle is, see Cursor
+	Query string `json:"-"`
+	Page  int    `json:"-"`
+}
+
+// BundleFromCursor rebuilds the Bundle a Cursor was taken from, enough to
+// request the page following it.
+func BundleFromCursor(client fhirInterface.IClient, cursor fhirInterface.Cursor) *BundleResult {
+	b := &BundleResult{
+		Client: client,
+		Query:  cursor.Query,
+		Page:   cursor.Page,
+	}
+	if cursor.NextLink != "" {
+		b.Link = append(b.Link, struct {
+			Relation string `json:"relation"`
+			Url      string `json:"url"`
+		}{
+			Relation: fhirInterface.LINK_NEXT,
+			Url:      cursor.NextLink,
+		})
+	}
+	return b
 }
 
 func (b *BundleResult) GetId() string {
 	return b.Id
 }
-func (b *BundleResult) GetNextLink() string {
+
+// GetLink returns the url of the link with that relation, or "" when the
+// Bundle has none. Servers using "prev" are found as previous.
+func (b *BundleResult) GetLink(relation string) string {
 	for _, link := range b.Link {
-		if link.Relation == "next" {
+		if link.Relation == relation || (relation == fhirInterface.LINK_PREVIOUS && link.Relation == "prev") {
 			return link.Url
 		}
 	}
 	return ""
 }
 
+func (b *BundleResult) GetSelfLink() string {
+	return b.GetLink(fhirInterface.LINK_SELF)
+}
+
+func (b *BundleResult) GetFirstLink() string {
+	return b.GetLink(fhirInterface.LINK_FIRST)
+}
+
+func (b *BundleResult) GetPreviousLink() string {
+	return b.GetLink(fhirInterface.LINK_PREVIOUS)
+}
+
+func (b *BundleResult) GetNextLink() string {
+	return b.GetLink(fhirInterface.LINK_NEXT)
+}
+
+func (b *BundleResult) GetLastLink() string {
+	return b.GetLink(fhirInterface.LINK_LAST)
+}
+
+// SetPosition records the search the Bundle belongs to and its page number.
+func (b *BundleResult) SetPosition(query string, page int) {
+	b.Query = query
+	b.Page = page
+}
+
+// Cursor returns the position after this page, to resume the search there.
+func (b *BundleResult) Cursor() fhirInterface.Cursor {
+	return fhirInterface.Cursor{
+		NextLink:  b.GetNextLink(),
+		Page:      b.Page,
+		Query:     b.Query,
+		Timestamp: time.Now(),
+	}
+}
+
+func (b *BundleResult) GetEntries() []fhirInterface.IEntry {
+	entries := make([]fhirInterface.IEntry, len(b.Entry))
+	for i := range b.Entry {
+		entries[i] = &b.Entry[i]
+	}
+	return entries
+}
+
+// GetOutcome gathers the issues of the search.mode = outcome entries, which
+// servers use to report warnings about the search itself. It returns nil when
+// the Bundle holds no such entry.
+func (b *BundleResult) GetOutcome() error {
+	outcome := &OperationOutcome{}
+	for _, e := range b.Entry {
+		if e.Search.Mode == "outcome" {
+			outcome.Issue = append(outcome.Issue, e.Resource.Issue...)
+		}
+	}
+	return outcome.Err()
+}
+
 func (b *BundleResult) MakeRequestNextPage() (fhirInterface.IRequest, error) {
-	nextLink := b.GetNextLink()
-	if nextLink == "" {
-		return nil, fmt.Errorf("No next link found")
+	return b.MakeRequestPage(fhirInterface.LINK_NEXT)
+}
+
+// MakeRequestPage builds the request of the page linked with relation, the
+// way the server profile of the client expects it.
+func (b *BundleResult) MakeRequestPage(relation string) (fhirInterface.IRequest, error) {
+	link := b.GetLink(relation)
+	if link == "" {
+		return nil, fmt.Errorf("No %s link found", relation)
 	}
-	u, err := url.Parse(nextLink)
+	u, err := url.Parse(link)
 	if err != nil {
 		return nil, err
 	}
+	page := b.pageNumber(relation)
 	q := u.Query()
-	// Esante v2 returns next links with an 'id' token; prefer this path regardless of prefix.
-	if q.Get("id") != "" {
+	pagination := b.Client.GetProfile().Pagination
+	// Esante v2 returns page links with an 'id' token; prefer this path regardless of prefix.
+	if pagination == fhirInterface.PAGINATION_ESANTE && q.Get("id") != "" {
 		return &r4.Request{
 			Client: b.Client,
 			Uri:    "/_page",
@@ -55,31 +148,74 @@ func (b *BundleResult) MakeRequestNextPage() (fhirInterface.IRequest, error) {
 				Id: q.Get("id"),
 			},
 			TypeReturned: fhirInterface.BUNDLE,
+			Query:        b.Query,
+			Page:         page,
 		}, nil
 	}
-	// Fallback to HAPI-style pagination with _getpages/_pageId/_bundletype
+	// HAPI-style pagination with _getpages/_pageId/_bundletype
+	if pagination == fhirInterface.PAGINATION_HAPI && q.Get("_getpages") != "" {
+		return &r4.Request{
+			Client: b.Client,
+			Uri:    "/",
+			Parameters: fhirInterface.UrlParameters{
+				GetPages:   q.Get("_getpages"),
+				PageId:     q.Get("_pageId"),
+				BundleType: q.Get("_bundletype"),
+			},
+			TypeReturned: fhirInterface.BUNDLE,
+			Query:        b.Query,
+			Page:         page,
+		}, nil
+	}
+	// Spec compliant servers: the link is opaque, follow it as is
 	return &r4.Request{
-		Client: b.Client,
-		Uri:    "/",
-		Parameters: fhirInterface.UrlParameters{
-			GetPages:   q.Get("_getpages"),
-			PageId:     q.Get("_pageId"),
-			BundleType: q.Get("_bundletype"),
-		},
+		Client:       b.Client,
+		Uri:          resolveLink(b.Client.GetBaseUrl(), u),
 		TypeReturned: fhirInterface.BUNDLE,
+		Query:        b.Query,
+		Page:         page,
 	}, nil
 }
 
+// pageNumber is the number of the page linked with relation, 0 when unknown.
+func (b *BundleResult) pageNumber(relation string) int {
+	switch {
+	case relation == fhirInterface.LINK_FIRST:
+		return 1
+	case b.Page == 0 || relation == fhirInterface.LINK_LAST:
+		return 0
+	case relation == fhirInterface.LINK_NEXT:
+		return b.Page + 1
+	case relation == fhirInterface.LINK_PREVIOUS:
+		return b.Page - 1
+	}
+	return b.Page
+}
+
+// resolveLink makes a relative link absolute, relative links being relative
+// to the service base URL.
+func resolveLink(baseUrl string, link *url.URL) string {
+	if link.IsAbs() {
+		return link.String()
+	}
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		return link.String()
+	}
+	base.Path = strings.TrimSuffix(base.Path, "/") + "/"
+	return base.ResolveReference(link).String()
+}
+
 type Bundle struct {
 	Client fhirInterface.IClient
 }
 
 func (org *Bundle) ById(id string) fhirInterface.IParameters {
-	fmt.Printf("\t\t--> ById()\n")
+	org.Client.GetLogger().Debug("ById", "id", id)
 	return nil
 }
 
 func (org *Bundle) Where(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	fmt.Printf("\t\t--> Where()\n")
+	org.Client.GetLogger().Debug("Where")
 	return nil
 }
@@ -19,7 +19,12 @@ type Entry struct {
 		Organization struct {
 			Reference string `json:"reference"`
 		} `json:"organization"`
+		// OperationOutcome fields
+		Issue []OperationOutcomeIssue `json:"issue"`
 	} `json:"resource"`
+	Search struct {
+		Mode string `json:"mode"`
+	} `json:"search"`
 }
 
 func (e *Entry) GetId() string {
@@ -0,0 +1,64 @@
+package models_r4
+
+import "strings"
+
+type OperationOutcome struct {
+	ResourceType string                  `json:"resourceType"`
+	Id           string                  `json:"id"`
+	Issue        []OperationOutcomeIssue `json:"issue"`
+}
+
+type OperationOutcomeIssue struct {
+	Severity string `json:"severity"`
+	Code     string `json:"code"`
+	Details  struct {
+		Text string `json:"text"`
+	} `json:"details"`
+	Diagnostics string   `json:"diagnostics"`
+	Expression  []string `json:"expression"`
+	Location    []string `json:"location"`
+}
+
+// Err returns the outcome as an *OperationOutcomeError, or nil when it has no issue.
+func (o *OperationOutcome) Err() error {
+	if o == nil || len(o.Issue) == 0 {
+		return nil
+	}
+	return &OperationOutcomeError{Issues: o.Issue}
+}
+
+// OperationOutcomeError reports the issues of an OperationOutcome sent back by
+// the server, either as an error response or as search.mode = outcome entries
+// of a Bundle.
+type OperationOutcomeError struct {
+	Issues []OperationOutcomeIssue
+}
+
+func (e *OperationOutcomeError) Error() string {
+	msgs := make([]string, 0, len(e.Issues))
+	for _, issue := range e.Issues {
+		msg := issue.Severity + " " + issue.Code
+		switch {
+		case issue.Diagnostics != "":
+			msg += ": " + issue.Diagnostics
+		case issue.Details.Text != "":
+			msg += ": " + issue.Details.Text
+		}
+		if len(issue.Expression) > 0 {
+			msg += " (" + strings.Join(issue.Expression, ", ") + ")"
+		}
+		msgs = append(msgs, msg)
+	}
+	return "OperationOutcome: " + strings.Join(msgs, "; ")
+}
+
+// HasErrors reports whether at least one issue is fatal or an error, as
+// opposed to warnings and information.
+func (e *OperationOutcomeError) HasErrors() bool {
+	for _, issue := range e.Issues {
+		if issue.Severity == "fatal" || issue.Severity == "error" {
+			return true
+		}
+	}
+	return false
+}
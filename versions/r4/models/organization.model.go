@@ -9,10 +9,15 @@ type Organization struct {
 	Client  fhirInterface.IClient
 	Address fhirInterface.FhirAddress
 	Name    fhirInterface.FhirName
+	// QualificationCode isn't a search parameter of Organization in FHIR R4,
+	// only servers defining it accept it.
+	QualificationCode fhirInterface.FhirQualificationCode
+	Identifier        fhirInterface.FhirIdentifier
+	LastUpdated       fhirInterface.FhirLastUpdated
 }
 
 func (org *Organization) ById(id string) fhirInterface.IParameters {
-	//fmt.Printf("\t\t--> ById()\n")
+	org.Client.GetLogger().Debug("ById", "id", id)
 
 	return &parameters_r4.OrganizationParameters{
 		Client: org.Client,
@@ -21,7 +26,7 @@ func (org *Organization) ById(id string) fhirInterface.IParameters {
 }
 
 func (org *Organization) Where(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Printf("\t\t--> Where()\n")
+	org.Client.GetLogger().Debug("Where")
 
 	return &parameters_r4.OrganizationParameters{
 		Client:     org.Client,
@@ -10,24 +10,24 @@ type Practitioner struct {
 	Address           fhirInterface.FhirAddress
 	Name              fhirInterface.FhirName
 	QualificationCode fhirInterface.FhirQualificationCode
+	Identifier        fhirInterface.FhirIdentifier
+	LastUpdated       fhirInterface.FhirLastUpdated
 	Active            fhirInterface.FhirActive
 }
 
 func (p *Practitioner) ById(id string) fhirInterface.IParameters {
-	//fmt.Printf("\t\t--> ById()\n")
+	p.Client.GetLogger().Debug("ById", "id", id)
 
 	// Use search on _id to allow combining with other parameters (e.g., qualification-code)
 	return &parameters_r4.PractitionerParameters{
-		Client: p.Client,
-		Uri:    "/Practitioner",
-		Parameters: fhirInterface.UrlParameters{
-			SearchId: id,
-		},
+		Client:     p.Client,
+		Uri:        "/Practitioner",
+		Parameters: fhirInterface.Param("_id", id),
 	}
 }
 
 func (p *Practitioner) Where(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Printf("\t\t--> Where()\n")
+	p.Client.GetLogger().Debug("Where")
 
 	return &parameters_r4.PractitionerParameters{
 		Client:     p.Client,
@@ -1,24 +1,27 @@
 package models_r4
 
 import (
-	"fmt"
-
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
 	parameters_r4 "github.com/LGMorgan/go-fhir/versions/r4/parameters"
 )
 
 type PractitionerRole struct {
-	Client    fhirInterface.IClient
-	Id        string
-	Address   fhirInterface.FhirAddress
-	Name      fhirInterface.FhirName
-	Role      fhirInterface.FhirRole
-	Active    fhirInterface.FhirActive
-	Reference string
+	Client fhirInterface.IClient
+	Id     string
+	// Address, Name and QualificationCode aren't search parameters of
+	// PractitionerRole in FHIR R4, only servers defining them accept them.
+	Address           fhirInterface.FhirAddress
+	Name              fhirInterface.FhirName
+	QualificationCode fhirInterface.FhirQualificationCode
+	Role              fhirInterface.FhirRole
+	Active            fhirInterface.FhirActive
+	Identifier        fhirInterface.FhirIdentifier
+	LastUpdated       fhirInterface.FhirLastUpdated
+	Reference         string
 }
 
 func (pr *PractitionerRole) ById(id string) fhirInterface.IParameters {
-	fmt.Printf("\t\t--> ById()\n")
+	pr.Client.GetLogger().Debug("ById", "id", id)
 
 	return &parameters_r4.PractitionerRoleParameters{
 		Client: pr.Client,
@@ -27,7 +30,7 @@ func (pr *PractitionerRole) ById(id string) fhirInterface.IParameters {
 }
 
 func (pr *PractitionerRole) Where(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	fmt.Printf("\t\t--> Where()\n")
+	pr.Client.GetLogger().Debug("Where")
 
 	return &parameters_r4.PractitionerRoleParameters{
 		Client:     pr.Client,
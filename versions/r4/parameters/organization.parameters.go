@@ -12,7 +12,7 @@ type OrganizationParameters struct {
 }
 
 func (org *OrganizationParameters) ReturnBundle() fhirInterface.IRequest {
-	//fmt.Println("\t\t\t--> ReturnBundle()")
+	org.Client.GetLogger().Debug("ReturnBundle", "uri", org.Uri)
 	return &r4.Request{
 		Client:       org.Client,
 		Uri:          org.Uri,
@@ -26,7 +26,7 @@ func (org *OrganizationParameters) Return() fhirInterface.IRequest {
 }
 
 func (org *OrganizationParameters) ReturnRaw() fhirInterface.IRequest {
-	//fmt.Println("\t\t\t--> ReturnRaw()")
+	org.Client.GetLogger().Debug("ReturnRaw", "uri", org.Uri)
 	return &r4.Request{
 		Client:       org.Client,
 		Uri:          org.Uri,
@@ -36,19 +36,19 @@ func (org *OrganizationParameters) ReturnRaw() fhirInterface.IRequest {
 }
 
 func (org *OrganizationParameters) And(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Println("\t\t--> And()")
+	org.Client.GetLogger().Debug("And", "uri", org.Uri)
 	org.Parameters = org.Parameters.Intersection(option)
 	return org
 }
 
 func (org *OrganizationParameters) Or(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Println("\t\t--> Or()")
+	org.Client.GetLogger().Debug("Or", "uri", org.Uri)
 	org.Parameters = org.Parameters.Union(option)
 	return org
 }
 
 func (org *OrganizationParameters) RevInclude(value string) fhirInterface.IParameters {
-	//fmt.Println("\t\t--> RevInclude()")
+	org.Client.GetLogger().Debug("RevInclude", "uri", org.Uri)
 	org.Parameters.RevInclude = value
 	return org
 }
@@ -12,7 +12,7 @@ type PractitionerParameters struct {
 }
 
 func (prac *PractitionerParameters) ReturnBundle() fhirInterface.IRequest {
-	//fmt.Println("\t\t\t--> ReturnBundle()")
+	prac.Client.GetLogger().Debug("ReturnBundle", "uri", prac.Uri)
 	return &r4.Request{
 		Client:       prac.Client,
 		Uri:          prac.Uri,
@@ -26,7 +26,7 @@ func (prac *PractitionerParameters) Return() fhirInterface.IRequest {
 }
 
 func (p *PractitionerParameters) ReturnRaw() fhirInterface.IRequest {
-	//fmt.Println("\t\t\t--> ReturnRaw()")
+	p.Client.GetLogger().Debug("ReturnRaw", "uri", p.Uri)
 	return &r4.Request{
 		Client:       p.Client,
 		Uri:          p.Uri,
@@ -36,19 +36,19 @@ func (p *PractitionerParameters) ReturnRaw() fhirInterface.IRequest {
 }
 
 func (prac *PractitionerParameters) And(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Println("\t\t\t--> And()")
+	prac.Client.GetLogger().Debug("And", "uri", prac.Uri)
 	prac.Parameters = prac.Parameters.Intersection(option)
 	return prac
 }
 
 func (prac *PractitionerParameters) Or(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	//fmt.Println("\t\t\t--> Or()")
+	prac.Client.GetLogger().Debug("Or", "uri", prac.Uri)
 	prac.Parameters = prac.Parameters.Union(option)
 	return prac
 }
 
 func (prac *PractitionerParameters) RevInclude(value string) fhirInterface.IParameters {
-	//fmt.Println("\t\t--> RevInclude()")
+	prac.Client.GetLogger().Debug("RevInclude", "uri", prac.Uri)
 	prac.Parameters.RevInclude = value
 	return prac
 }
@@ -1,8 +1,6 @@
 package parameters_r4
 
 import (
-	"fmt"
-
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
 	"github.com/LGMorgan/go-fhir/versions/r4"
 )
@@ -14,7 +12,7 @@ type PractitionerRoleParameters struct {
 }
 
 func (pr *PractitionerRoleParameters) ReturnBundle() fhirInterface.IRequest {
-	fmt.Println("\t\t\t--> ReturnBundle()")
+	pr.Client.GetLogger().Debug("ReturnBundle", "uri", pr.Uri)
 	return &r4.Request{
 		Client:       pr.Client,
 		Uri:          pr.Uri,
@@ -24,12 +22,12 @@ func (pr *PractitionerRoleParameters) ReturnBundle() fhirInterface.IRequest {
 }
 
 func (pr *PractitionerRoleParameters) Return() fhirInterface.IRequest {
-	fmt.Println("\t\t\t--> Return()")
+	pr.Client.GetLogger().Debug("Return", "uri", pr.Uri)
 	return nil
 }
 
 func (pr *PractitionerRoleParameters) ReturnRaw() fhirInterface.IRequest {
-	fmt.Println("\t\t\t--> ReturnRaw()")
+	pr.Client.GetLogger().Debug("ReturnRaw", "uri", pr.Uri)
 	return &r4.Request{
 		Client:       pr.Client,
 		Uri:          pr.Uri,
@@ -39,19 +37,19 @@ func (pr *PractitionerRoleParameters) ReturnRaw() fhirInterface.IRequest {
 }
 
 func (pr *PractitionerRoleParameters) And(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	fmt.Println("\t\t--> And()")
+	pr.Client.GetLogger().Debug("And", "uri", pr.Uri)
 	pr.Parameters = pr.Parameters.Intersection(option)
 	return pr
 }
 
 func (pr *PractitionerRoleParameters) Or(option fhirInterface.UrlParameters) fhirInterface.IParameters {
-	fmt.Println("\t\t--> Or()")
+	pr.Client.GetLogger().Debug("Or", "uri", pr.Uri)
 	pr.Parameters = pr.Parameters.Union(option)
 	return pr
 }
 
 func (pr *PractitionerRoleParameters) RevInclude(value string) fhirInterface.IParameters {
-	fmt.Println("\t\t--> RevInclude()")
+	pr.Client.GetLogger().Debug("RevInclude", "uri", pr.Uri)
 	pr.Parameters.RevInclude = value
 	return pr
 }
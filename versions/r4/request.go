@@ -1,7 +1,7 @@
 package r4
 
 import (
-	"fmt"
+	"context"
 
 	fhirInterface "github.com/LGMorgan/go-fhir/interface"
 )
@@ -11,26 +11,70 @@ type Request struct {
 	Uri          string
 	Parameters   fhirInterface.UrlParameters
 	TypeReturned fhirInterface.ResourceType
+	// Query and Page locate a page request in the search it continues, they
+	// are left empty for the search itself.
+	Query string
+	Page  int
 }
 
 func (req *Request) Execute() interface{} {
-	var err error
+	return req.ExecuteContext(context.Background())
+}
+
+func (req *Request) ExecuteContext(ctx context.Context) interface{} {
 	if req.TypeReturned == fhirInterface.RAW {
-		//fmt.Println("\t\t\t\t--> ExecuteRaw()")
-		var resRaw []byte
-		resRaw, err = req.Client.GetRaw(req.Uri, req.Parameters)
+		resRaw, err := req.ExecuteRawContext(ctx)
 		if err != nil {
-			fmt.Println(err)
+			req.Client.GetLogger().Error("ExecuteRaw failed", "uri", req.Uri, "error", err)
 			return nil
 		}
 		return resRaw
 	}
-	//fmt.Println("\t\t\t\t--> Execute()")
-	var res fhirInterface.IResourceResult
-	res, err = req.Client.Get(req.Uri, req.Parameters, req.TypeReturned)
+	res, err := req.Client.GetContext(ctx, req.Uri, req.Parameters, req.TypeReturned)
 	if err != nil {
-		fmt.Println(err)
+		req.Client.GetLogger().Error("Execute failed", "uri", req.Uri, "error", err)
 		return nil
 	}
 	return res
 }
+
+// ExecuteBundle runs the request and decodes the response as a Bundle.
+// Non-2xx answers are reported as *fhirInterface.HttpError.
+func (req *Request) ExecuteBundle() (fhirInterface.IResourceResult, error) {
+	return req.ExecuteBundleContext(context.Background())
+}
+
+func (req *Request) ExecuteBundleContext(ctx context.Context) (fhirInterface.IResourceResult, error) {
+	res, err := req.Client.GetContext(ctx, req.Uri, req.Parameters, fhirInterface.BUNDLE)
+	if err != nil {
+		return nil, err
+	}
+	// Let the result know where it stands, for its Cursor
+	if positioned, ok := res.(interface{ SetPosition(query string, page int) }); ok {
+		query, page := req.Query, req.Page
+		if query == "" {
+			query, page = req.search(), 1
+		}
+		positioned.SetPosition(query, page)
+	}
+	return res, nil
+}
+
+// search identifies the search run by the request, as a Cursor Query.
+func (req *Request) search() string {
+	values := req.Parameters.BuildUrlValues()
+	if len(values) == 0 {
+		return req.Uri
+	}
+	return req.Uri + "?" + values.Encode()
+}
+
+// ExecuteRaw runs the request and returns the response body untouched.
+// Non-2xx answers are reported as *fhirInterface.HttpError.
+func (req *Request) ExecuteRaw() ([]byte, error) {
+	return req.ExecuteRawContext(context.Background())
+}
+
+func (req *Request) ExecuteRawContext(ctx context.Context) ([]byte, error) {
+	return req.Client.GetRawContext(ctx, req.Uri, req.Parameters)
+}